@@ -2,6 +2,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"strings"
@@ -9,21 +10,118 @@ import (
 	"github.com/jason-dour/diceprob"
 )
 
+var (
+	fractions = flag.Bool("fractions", false, "print exact probabilities as fractions")
+	quantiles = flag.Bool("quantiles", false, "print the outcome at each common percentile")
+	verify    = flag.Int("verify", 0, "roll the expression `N` times, and test the rolls against the distribution")
+)
+
 func main() {
-	dize, err := diceprob.New(os.Args[1])
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-fractions] [-quantiles] [-verify N] <expression>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [-fractions] [-quantiles] vs <expression> <expression>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch {
+	case flag.NArg() == 3 && flag.Arg(0) == "vs":
+		versus(flag.Arg(1), flag.Arg(2))
+	case flag.NArg() == 1:
+		single(flag.Arg(0))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// single - Calculate and display a single expression.
+func single(expression string) {
+	dize, err := diceprob.New(expression)
 	if err != nil {
-		panic(err)
+		fail(err)
 	}
 
-	dize.Calculate()
+	if err := dize.Calculate(); err != nil {
+		fail(err)
+	}
 
 	fmt.Printf("Expression: %s\n", dize.Expression())
+	if *verify > 0 {
+		chiSquare, pValue, err := dize.Verify(*verify)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("Verify: %d rolls, chi-square %.6g, p-value %.6g\n", *verify, chiSquare, pValue)
+	}
+	display(dize)
+}
+
+// versus - Calculate and display an opposed roll of two expressions.
+func versus(expression1, expression2 string) {
+	dize1, err := diceprob.New(expression1)
+	if err != nil {
+		fail(err)
+	}
+	dize2, err := diceprob.New(expression2)
+	if err != nil {
+		fail(err)
+	}
+
+	opposed, err := diceprob.Versus(dize1, dize2)
+	if err != nil {
+		fail(err)
+	}
+
+	fmt.Printf("Expression: %s vs %s\n", dize1.Expression(), dize2.Expression())
+	fmt.Printf("Win: %.6g\n", opposed.Win())
+	fmt.Printf("Tie: %.6g\n", opposed.Tie())
+	fmt.Printf("Lose: %.6g\n", opposed.Lose())
+	fmt.Printf("Margin: %s\n", opposed.Margin().Expression())
+	display(opposed.Margin())
+}
+
+// display - Display the calculated statistics and distribution.
+func display(dize *diceprob.DiceProb) {
 	fmt.Printf("Bounds: %v..%v\n", dize.Min(), dize.Max())
-	fmt.Printf("Permutations: %v\n", dize.Outcomes())
+	fmt.Printf("Permutations: %v\n", dize.Permutations())
 	fmt.Printf("Outcome Set: %s\n", strings.Join(*dize.OutcomesString(), ","))
-	fmt.Printf("Distribution:\n  Outcome | Frequency | Probability\n")
+	if dize.TruncatedProbability() > 0 {
+		fmt.Printf("Truncated: %.6g\n", dize.TruncatedProbability())
+	}
+	fmt.Printf("Mean: %.6g\n", dize.Mean())
+	fmt.Printf("Variance: %.6g\n", dize.Variance())
+	fmt.Printf("Std Dev: %.6g\n", dize.StdDev())
+	fmt.Printf("Skewness: %.6g\n", dize.Skewness())
+	fmt.Printf("Kurtosis: %.6g\n", dize.Kurtosis())
+	fmt.Printf("Mode: %v\n", *dize.Mode())
+	fmt.Printf("Median: %v\n", dize.Median())
+	if *quantiles {
+		percents := []float64{5, 10, 25, 50, 75, 90, 95}
+		fmt.Printf("Quantiles:\n")
+		for i, outcome := range *dize.Percentiles(percents...) {
+			fmt.Printf("  p%-6v  %d\n", percents[i], outcome)
+		}
+	}
+	fmt.Printf("Distribution:\n  Outcome | Frequency | Probability | >=          | <=\n")
 
+	if *fractions {
+		survival, cdf := *dize.ExactSurvival(), *dize.ExactCDF()
+		for _, i := range *dize.Outcomes() {
+			fmt.Printf("  %-8d  %-8d    %-12s  %-12s  %s\n", i, (*dize.Distribution())[i], (*dize.ExactProbabilities())[i].RatString(),
+				survival[i].RatString(), cdf[i].RatString())
+		}
+		return
+	}
+	survival, cdf := *dize.Survival(), *dize.CDF()
 	for _, i := range *dize.Outcomes() {
-		fmt.Printf("  %-8d  %-8d    %.6g\n", i, (*dize.Distribution())[i], (*dize.Probabilities())[i])
+		fmt.Printf("  %-8d  %-8d    %-12.6g  %-12.6g  %.6g\n", i, (*dize.Distribution())[i], (*dize.Probabilities())[i],
+			survival[i], cdf[i])
 	}
 }
+
+// fail - Report the error and exit.
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	os.Exit(1)
+}
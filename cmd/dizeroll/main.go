@@ -2,17 +2,114 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"math"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/alecthomas/repr"
 	"github.com/jason-dour/diceprob"
 )
 
 func main() {
-	dize, err := diceprob.New(os.Args[1])
+	seed := flag.Int64("seed", 0, "seed the random source, so the roll can be repeated")
+	verbose := flag.Bool("verbose", false, "print every die rolled, along with the outcome")
+	n := flag.Int("n", 1, "roll the expression `N` times, and print a histogram of the outcomes")
+	exact := flag.Bool("exact", false, "with -n, print the exact probabilities and the deviation from them")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-seed N] [-verbose] [-n N [-exact]] <expression>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *n < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Only seed the random source when asked to.
+	opts := []diceprob.Option{}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			opts = append(opts, diceprob.WithSeed(*seed))
+		}
+	})
+
+	dize, err := diceprob.New(flag.Arg(0), opts...)
 	if err != nil {
-		panic(err)
+		fail(err)
+	}
+
+	if *n > 1 {
+		histogram(dize, *n, *exact)
+		return
 	}
+
 	// repr.Println(dize.ParsedExpression())
-	repr.Println(dize.ParsedExpression().Roll())
+	result, err := dize.RollDetailed()
+	if err != nil {
+		fail(err)
+	}
+	if *verbose {
+		fmt.Println(result)
+		return
+	}
+	repr.Println(result.Total)
+}
+
+// histogram - Roll the expression n times and display how often each outcome was rolled.
+func histogram(dize *diceprob.DiceProb, n int, exact bool) {
+	counts, err := dize.RollMany(n)
+	if err != nil {
+		fail(err)
+	}
+
+	// Include every outcome rolled, and every outcome possible when comparing to the exact probabilities.
+	probabilities := map[int64]float64{}
+	if exact {
+		if err := dize.Calculate(); err != nil {
+			fail(err)
+		}
+		probabilities = *dize.Probabilities()
+	}
+	outcomes := []int64{}
+	highest := int64(0)
+	for outcome, count := range *counts {
+		outcomes = append(outcomes, outcome)
+		if count > highest {
+			highest = count
+		}
+	}
+	for outcome := range probabilities {
+		if _, ok := (*counts)[outcome]; !ok {
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	sort.Slice(outcomes, func(i, j int) bool { return outcomes[i] < outcomes[j] })
+
+	fmt.Printf("Expression: %s\n", dize.Expression())
+	fmt.Printf("Rolls: %d\n", n)
+	if exact {
+		fmt.Printf("Histogram:\n  Outcome | Count     | Observed    | Exact       | Deviation\n")
+	} else {
+		fmt.Printf("Histogram:\n  Outcome | Count     | Observed\n")
+	}
+	for _, outcome := range outcomes {
+		count := (*counts)[outcome]
+		observed := float64(count) / float64(n)
+		bar := strings.Repeat("#", int(math.Round(40*float64(count)/float64(highest))))
+		if exact {
+			fmt.Printf("  %-8d  %-9d   %-12.6g  %-12.6g  %-+12.6g  %s\n", outcome, count, observed, probabilities[outcome],
+				observed-probabilities[outcome], bar)
+			continue
+		}
+		fmt.Printf("  %-8d  %-9d   %-12.6g  %s\n", outcome, count, observed, bar)
+	}
+}
+
+// fail - Report the error and exit.
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	os.Exit(1)
 }
@@ -1,13 +1,54 @@
 // Package diceprob - Calculating outcome distributions and probabilities for complicated dice expressions.
 package diceprob
 
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+)
+
+// Errors returned while parsing, calculating or rolling an expression.
+var (
+	ErrSyntax              = errors.New("syntax error")          // The expression cannot be parsed.
+	ErrInvalidDice         = errors.New("invalid dice roll")     // The dice roll cannot be rolled.
+	ErrDivideByZero        = errors.New("division by zero")      // A division's right-hand side is zero.
+	ErrOverflow            = errors.New("integer overflow")      // A value does not fit in an int64.
+	ErrUnsupportedOperator = errors.New("unsupported operator")  // The operator is not known.
+	ErrInvalidFunction     = errors.New("invalid function call") // The function is not known, or given the wrong arguments.
+	ErrInvalidSamples      = errors.New("invalid sample count")  // Fewer than one sample was asked for.
+)
+
 // DiceProb - Base data structure.
 type DiceProb struct {
-	expression    string             // Expression provided when creating the instance.
-	parsed        *Expression        // Parsed expression data structure.
-	outcomes      *[]int64           // List of outcome values.
-	permutations  int64              // Total number of outcomes.
-	distribution  *map[int64]int64   // Distribution of summed outcomes and their frequency.
-	probabilities *map[int64]float64 // Probability of each outcome.
-	bounds        *[]int64           // Min/Max Bounds of the outcomes.
+	expression    string              // Expression provided when creating the instance.
+	parsed        *Expression         // Parsed expression data structure.
+	outcomes      *[]int64            // List of outcome values.
+	permutations  *big.Int            // Total number of outcomes, including those cut off.
+	distribution  *map[int64]*big.Int // Distribution of summed outcomes and their frequency.
+	probabilities *map[int64]float64  // Probability of each outcome.
+	exact         *map[int64]*big.Rat // Exact probability of each outcome.
+	bounds        *[]int64            // Min/Max Bounds of the outcomes.
+	defined       *big.Int            // Frequency of the outcomes in the distribution.
+	truncated     *big.Int            // Frequency of outcomes cut off by the explosion depth.
+	undefined     *big.Int            // Frequency of outcomes excluded by division by zero.
+	options       *options            // Options provided when creating the instance.
 }
+
+// Option - Setting applied to a DiceProb instance when it is created.
+type Option func(*options)
+
+// options - Settings used while calculating and rolling an expression.
+type options struct {
+	explodeDepth int64        // Maximum number of explosions followed per die when calculating.
+	divideByZero DivideByZero // Handling of division by zero when calculating.
+	source       rand.Source  // Random source used when rolling.
+}
+
+// DivideByZero - Policy for handling division by zero when calculating a distribution.
+type DivideByZero int
+
+// DivideByZero constants
+const (
+	DivideByZeroError   DivideByZero = iota // Return ErrDivideByZero from Calculate.
+	DivideByZeroExclude                     // Exclude the outcomes, reporting them by UndefinedProbability.
+)
@@ -1,6 +1,10 @@
 package diceprob
 
 import (
+	"errors"
+	"math"
+	"math/big"
+	"math/rand"
 	"reflect"
 	"sort"
 	"testing"
@@ -8,6 +12,15 @@ import (
 	"github.com/alecthomas/repr"
 )
 
+// int64Distribution - Convert a distribution to int64 frequencies, for comparison with control distributions.
+func int64Distribution(d *map[int64]*big.Int) map[int64]int64 {
+	ret := map[int64]int64{}
+	for outcome, frequency := range *d {
+		ret[outcome] = frequency.Int64()
+	}
+	return ret
+}
+
 func TestNew(t *testing.T) {
 	expected := "3d6"
 	t.Logf("expected=%v", expected)
@@ -26,9 +39,11 @@ func TestNew(t *testing.T) {
 func TestParsed(t *testing.T) {
 	expr := DiceRoll("3d6")
 	expected := repr.String(&Expression{
-		Left: &Term{
-			Left: &Atom{
-				RollExpr: &expr,
+		Sum: Sum{
+			Left: &Term{
+				Left: &Atom{
+					RollExpr: &expr,
+				},
 			},
 		},
 	})
@@ -53,7 +68,10 @@ func TestRoll(t *testing.T) {
 	t.Logf("d=%v", d)
 	d.Calculate()
 	t.Logf("expected=(%v <= Roll() <= %v)", d.Min(), d.Max())
-	actual := d.Roll()
+	actual, err := d.Roll()
+	if err != nil {
+		t.Errorf("Could not roll the expression.")
+	}
 	t.Logf("expected=(%v <= %v <= %v)", d.Min(), actual, d.Max())
 	if !((actual >= d.Min()) && (actual <= d.Max())) {
 		t.Errorf("Rolled value outside of bounds.")
@@ -86,7 +104,7 @@ func TestDistributionNdS(t *testing.T) {
 		18: 1,
 	}
 	t.Logf("expected=%v", expected)
-	actual := *d.Distribution()
+	actual := int64Distribution(d.Distribution())
 	t.Logf("actual=%v", actual)
 	eq := reflect.DeepEqual(expected, actual)
 	t.Logf("DeepEqual?=%v", eq)
@@ -112,7 +130,7 @@ func TestDistributionNdF(t *testing.T) {
 		3:  1,
 	}
 	t.Logf("expected=%v", expected)
-	actual := *d.Distribution()
+	actual := int64Distribution(d.Distribution())
 	t.Logf("actual=%v", actual)
 	eq := reflect.DeepEqual(expected, actual)
 	t.Logf("DeepEqual?=%v", eq)
@@ -151,7 +169,7 @@ func TestDistributionMidS(t *testing.T) {
 		20: 58,
 	}
 	t.Logf("expected=%v", expected)
-	actual := *d.Distribution()
+	actual := int64Distribution(d.Distribution())
 	t.Logf("actual=%v", actual)
 	eq := reflect.DeepEqual(expected, actual)
 	t.Logf("DeepEqual?=%v", eq)
@@ -173,7 +191,7 @@ func TestDistributionMidF(t *testing.T) {
 		1:  7,
 	}
 	t.Logf("expected=%v", expected)
-	actual := *d.Distribution()
+	actual := int64Distribution(d.Distribution())
 	t.Logf("actual=%v", actual)
 	eq := reflect.DeepEqual(expected, actual)
 	t.Logf("DeepEqual?=%v", eq)
@@ -201,7 +219,7 @@ func TestCombinedDistributions(t *testing.T) {
 	t.Logf("d2.Distribution()=%v", d2.Distribution())
 	t.Logf("d2.Probabilities()=%v", d2.Probabilities())
 
-	eq := reflect.DeepEqual(d1.Distribution(), d2.Distribution())
+	eq := reflect.DeepEqual(int64Distribution(d1.Distribution()), int64Distribution(d2.Distribution()))
 	t.Logf("Distribution.DeepEqual?=%v", eq)
 	if !eq {
 		t.Errorf("Distribution of (%s) does not match distribution of (%s).", d1.ParsedExpression().String(), d2.ParsedExpression().String())
@@ -265,3 +283,1420 @@ func TestSameProbabilities(t *testing.T) {
 		t.Errorf("Probabilities of (%s) do not match Probabilities of (%s).", d1.ParsedExpression().String(), d2.ParsedExpression().String())
 	}
 }
+
+func TestDistributionKeepHighest(t *testing.T) {
+	d, err := New("4d6kh3")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	expected := map[int64]int64{
+		3:  1,
+		4:  4,
+		5:  10,
+		6:  21,
+		7:  38,
+		8:  62,
+		9:  91,
+		10: 122,
+		11: 148,
+		12: 167,
+		13: 172,
+		14: 160,
+		15: 131,
+		16: 94,
+		17: 54,
+		18: 21,
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+}
+
+func TestDistributionKeepDropEquivalence(t *testing.T) {
+	pairs := [][]string{
+		{"4d6kh3", "4d6dl1"},
+		{"2d20kl1", "2d20dh1"},
+		{"5dFkh2", "5dFdl3"},
+	}
+	for _, pair := range pairs {
+		d1, err := New(pair[0])
+		if err != nil {
+			t.Errorf("Could not create new d1 instance.")
+		}
+		d1.Calculate()
+		d2, err := New(pair[1])
+		if err != nil {
+			t.Errorf("Could not create new d2 instance.")
+		}
+		d2.Calculate()
+		t.Logf("%s=%v", pair[0], d1.Distribution())
+		t.Logf("%s=%v", pair[1], d2.Distribution())
+		if !reflect.DeepEqual(int64Distribution(d1.Distribution()), int64Distribution(d2.Distribution())) {
+			t.Errorf("Distribution of (%s) does not match distribution of (%s).", pair[0], pair[1])
+		}
+	}
+}
+
+func TestDistributionKeepLowest(t *testing.T) {
+	d, err := New("2d20kl1")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	expected := map[int64]int64{}
+	for outcome := int64(1); outcome <= 20; outcome++ {
+		expected[outcome] = 41 - (2 * outcome)
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+}
+
+func TestRollKeep(t *testing.T) {
+	for _, expr := range []string{"4d6kh3", "2d20kl1", "3dFdl1"} {
+		d, err := New(expr)
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		d.Calculate()
+		for i := 0; i < 100; i++ {
+			actual, err := d.Roll()
+			if err != nil {
+				t.Errorf("Could not roll the expression.")
+			}
+			if !((actual >= d.Min()) && (actual <= d.Max())) {
+				t.Errorf("Rolled value %v of (%s) outside of bounds %v..%v.", actual, expr, d.Min(), d.Max())
+			}
+		}
+	}
+}
+
+func TestDistributionExplode(t *testing.T) {
+	d, err := New("1d6!", WithExplodeDepth(1))
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	expected := map[int64]int64{
+		1:  6,
+		2:  6,
+		3:  6,
+		4:  6,
+		5:  6,
+		7:  1,
+		8:  1,
+		9:  1,
+		10: 1,
+		11: 1,
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+	t.Logf("Permutations()=%v TruncatedProbability()=%v", d.Permutations(), d.TruncatedProbability())
+	if d.Permutations().Int64() != 36 || d.TruncatedProbability() != float64(1)/36 {
+		t.Errorf("Truncated probability does not match the cut off explosion.")
+	}
+}
+
+func TestExplodeDepthRange(t *testing.T) {
+	for depth, clamped := range map[int64]int64{-3: 0, 1000: 100} {
+		d1, err := New("1d6!", WithExplodeDepth(depth))
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		d2, err := New("1d6!", WithExplodeDepth(clamped))
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		d1.Calculate()
+		d2.Calculate()
+		t.Logf("depth=%v clamped=%v", depth, clamped)
+		if !reflect.DeepEqual(d1.Distribution(), d2.Distribution()) || d1.TruncatedProbability() != d2.TruncatedProbability() {
+			t.Errorf("Explosion depth %v was not clamped to %v.", depth, clamped)
+		}
+	}
+}
+
+func TestDistributionExplodeCondition(t *testing.T) {
+	d, err := New("1d10!>=9")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	total := 0.0
+	for _, probability := range *d.Probabilities() {
+		total = total + probability
+	}
+	total = total + d.TruncatedProbability()
+	t.Logf("total=%v", total)
+	if math.Abs(total-1) > 1e-9 {
+		t.Errorf("Probabilities and truncated probability do not sum to 1.")
+	}
+	if _, ok := (*d.Distribution())[9]; ok {
+		t.Errorf("Exploding face 9 appears as a final outcome.")
+	}
+	for i := 0; i < 100; i++ {
+		if actual, err := d.Roll(); err != nil || actual < d.Min() {
+			t.Errorf("Rolled value %v below minimum %v.", actual, d.Min())
+		}
+	}
+}
+
+func TestDistributionPenetrate(t *testing.T) {
+	d, err := New("1d6!p", WithExplodeDepth(1))
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	expected := map[int64]int64{
+		1:  6,
+		2:  6,
+		3:  6,
+		4:  6,
+		5:  6,
+		6:  1,
+		7:  1,
+		8:  1,
+		9:  1,
+		10: 1,
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+}
+
+func TestDistributionCompound(t *testing.T) {
+	d1, err := New("3d6!!")
+	if err != nil {
+		t.Errorf("Could not create new d1 instance.")
+	}
+	d1.Calculate()
+	d2, err := New("3d6!")
+	if err != nil {
+		t.Errorf("Could not create new d2 instance.")
+	}
+	d2.Calculate()
+	t.Logf("d1.Distribution()=%v", d1.Distribution())
+	t.Logf("d2.Distribution()=%v", d2.Distribution())
+	if !reflect.DeepEqual(int64Distribution(d1.Distribution()), int64Distribution(d2.Distribution())) {
+		t.Errorf("Distribution of (%s) does not match distribution of (%s).", d1.Expression(), d2.Expression())
+	}
+	if d1.TruncatedProbability() != d2.TruncatedProbability() {
+		t.Errorf("Truncated probability of (%s) does not match that of (%s).", d1.Expression(), d2.Expression())
+	}
+}
+
+func TestDistributionExplodePool(t *testing.T) {
+	d, err := New("2d2!kh1", WithExplodeDepth(1))
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	// Each die rolls 1, or 2 and then 1 as a new die in the pool; the highest die is 2 unless both rolled 1.
+	expected := map[int64]int64{
+		1: 4,
+		2: 5,
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+	if d.TruncatedProbability() != float64(7)/16 {
+		t.Errorf("Truncated probability does not match the cut off explosions.")
+	}
+
+	// Compounding dice are kept or dropped on their totals, rather than roll by roll.
+	for _, pair := range [][]string{{"2d6!kh1", "2d6!!kh1"}, {"3d6!o2", "3d6!!o2"}, {"4d6!dl1", "4d6!!dl1"}} {
+		d1, err := New(pair[0])
+		if err != nil {
+			t.Errorf("Could not create new d1 instance.")
+		}
+		d1.Calculate()
+		d2, err := New(pair[1])
+		if err != nil {
+			t.Errorf("Could not create new d2 instance.")
+		}
+		d2.Calculate()
+		t.Logf("%s=%v", pair[0], d1.Distribution())
+		t.Logf("%s=%v", pair[1], d2.Distribution())
+		if reflect.DeepEqual(int64Distribution(d1.Distribution()), int64Distribution(d2.Distribution())) {
+			t.Errorf("Distribution of (%s) matches distribution of (%s).", pair[0], pair[1])
+		}
+	}
+}
+
+func TestDistributionRerollOnce(t *testing.T) {
+	d, err := New("1d6r1")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	expected := map[int64]int64{
+		1: 1,
+		2: 7,
+		3: 7,
+		4: 7,
+		5: 7,
+		6: 7,
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+}
+
+func TestDistributionRerollUntil(t *testing.T) {
+	d, err := New("2d6rr<3")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	expected := map[int64]int64{
+		6:  1,
+		7:  2,
+		8:  3,
+		9:  4,
+		10: 3,
+		11: 2,
+		12: 1,
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+	for i := 0; i < 100; i++ {
+		actual, err := d.Roll()
+		if err != nil {
+			t.Errorf("Could not roll the expression.")
+		}
+		if !((actual >= d.Min()) && (actual <= d.Max())) {
+			t.Errorf("Rolled value %v outside of bounds %v..%v.", actual, d.Min(), d.Max())
+		}
+	}
+}
+
+func TestDistributionSuccesses(t *testing.T) {
+	d, err := New("10d10>=8")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	expected := map[int64]int64{}
+	for successes := int64(0); successes <= 10; successes++ {
+		frequency := big.NewInt(0).Binomial(10, successes)
+		frequency.Mul(frequency, big.NewInt(0).Exp(big.NewInt(3), big.NewInt(successes), nil))
+		frequency.Mul(frequency, big.NewInt(0).Exp(big.NewInt(7), big.NewInt(10-successes), nil))
+		expected[successes] = frequency.Int64()
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+	for i := 0; i < 100; i++ {
+		actual, err := d.Roll()
+		if err != nil {
+			t.Errorf("Could not roll the expression.")
+		}
+		if !((actual >= d.Min()) && (actual <= d.Max())) {
+			t.Errorf("Rolled value %v outside of bounds %v..%v.", actual, d.Min(), d.Max())
+		}
+	}
+}
+
+func TestDistributionSuccessesFailures(t *testing.T) {
+	d, err := New("2d10>=8f1")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	expected := map[int64]int64{
+		-2: 1,
+		-1: 12,
+		0:  42,
+		1:  36,
+		2:  9,
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+}
+
+func TestDistributionSuccessesExplode(t *testing.T) {
+	d1, err := New("1d10!=10>=8", WithExplodeDepth(1))
+	if err != nil {
+		t.Errorf("Could not create new d1 instance.")
+	}
+	d1.Calculate()
+	d2, err := New("1d10!!=10>=8", WithExplodeDepth(1))
+	if err != nil {
+		t.Errorf("Could not create new d2 instance.")
+	}
+	d2.Calculate()
+	t.Logf("d1.Distribution()=%v", d1.Distribution())
+	t.Logf("d2.Distribution()=%v", d2.Distribution())
+	// Exploding dice score every roll; a 10 followed by an 8 or 9 is two successes.
+	if int64Distribution(d1.Distribution())[2] != 2 {
+		t.Errorf("Exploding dice do not score each roll.")
+	}
+	// Compounding dice score once; a 10 followed by anything but another 10 is a single success.
+	if _, ok := (*d2.Distribution())[2]; ok || int64Distribution(d2.Distribution())[1] != 20+9 {
+		t.Errorf("Compounding dice do not score once.")
+	}
+}
+
+func TestDistributionCustomFaces(t *testing.T) {
+	d, err := New("1d{1,1,2,3,5,8}")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	d.Calculate()
+	expected := map[int64]int64{
+		1: 2,
+		2: 1,
+		3: 1,
+		5: 1,
+		8: 1,
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+}
+
+func TestDistributionNamedDie(t *testing.T) {
+	err := RegisterDie("avg", []int64{2, 3, 3, 4, 4, 5})
+	if err != nil {
+		t.Errorf("Could not register die.")
+	}
+	pairs := [][]string{
+		{"3davg", "3d{2,3,3,4,4,5}"},
+		{"4dAvgkh3", "4d{2,3,3,4,4,5}kh3"},
+		{"3dF", "3d{-1,0,1}"},
+	}
+	for _, pair := range pairs {
+		d1, err := New(pair[0])
+		if err != nil {
+			t.Errorf("Could not create new d1 instance.")
+		}
+		d1.Calculate()
+		d2, err := New(pair[1])
+		if err != nil {
+			t.Errorf("Could not create new d2 instance.")
+		}
+		d2.Calculate()
+		t.Logf("%s=%v", pair[0], d1.Distribution())
+		t.Logf("%s=%v", pair[1], d2.Distribution())
+		if !reflect.DeepEqual(int64Distribution(d1.Distribution()), int64Distribution(d2.Distribution())) {
+			t.Errorf("Distribution of (%s) does not match distribution of (%s).", pair[0], pair[1])
+		}
+		for i := 0; i < 100; i++ {
+			actual, err := d1.Roll()
+			if err != nil {
+				t.Errorf("Could not roll the expression.")
+			}
+			if !((actual >= d1.Min()) && (actual <= d1.Max())) {
+				t.Errorf("Rolled value %v outside of bounds %v..%v.", actual, d1.Min(), d1.Max())
+			}
+		}
+	}
+	if err := RegisterDie("d6", []int64{1}); !errors.Is(err, ErrInvalidDice) {
+		t.Errorf("Registered a die with an invalid name.")
+	}
+	if err := RegisterDie("empty", []int64{}); !errors.Is(err, ErrInvalidDice) {
+		t.Errorf("Registered a die with no faces.")
+	}
+
+	// Registering a die again replaces the faces of dice already parsed.
+	for _, face := range []int64{1, 2} {
+		if err := RegisterDie("swap", []int64{face}); err != nil {
+			t.Errorf("Could not register die.")
+		}
+		d, err := New("1dswap")
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		if actual, err := d.Roll(); err != nil || actual != face {
+			t.Errorf("Rolled value %v does not match the registered face %v.", actual, face)
+		}
+	}
+}
+
+func TestInvalidDice(t *testing.T) {
+	for _, expr := range []string{"0d6", "1d0", "4d6kh5", "1d1!", "1d6rr<7", "3dnosuchdie"} {
+		_, err := New(expr)
+		t.Logf("New(%q)=%v", expr, err)
+		if !errors.Is(err, ErrInvalidDice) {
+			t.Errorf("Invalid dice roll (%s) did not return ErrInvalidDice.", expr)
+		}
+	}
+}
+
+func TestLargeDie(t *testing.T) {
+	d, err := New("1d100000000000")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	for i := 0; i < 100; i++ {
+		actual, err := d.Roll()
+		if err != nil || actual < 1 || actual > 100000000000 {
+			t.Errorf("Rolled value %v outside of bounds 1..100000000000.", actual)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	for _, expr := range []string{"1d6 /", "(1d6", "1d6 +* 2", ""} {
+		_, err := New(expr)
+		t.Logf("New(%q)=%v", expr, err)
+		if !errors.Is(err, ErrSyntax) {
+			t.Errorf("Invalid expression (%s) did not return ErrSyntax.", expr)
+		}
+	}
+}
+
+func TestCalculateErrors(t *testing.T) {
+	tests := map[string]error{
+		"1d6/0":                 ErrDivideByZero,
+		"1d6/(1d3-2)":           ErrDivideByZero,
+		"9223372036854775807+1": ErrOverflow,
+	}
+	for expr, expected := range tests {
+		d, err := New(expr)
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		err = d.Calculate()
+		t.Logf("Calculate(%q)=%v", expr, err)
+		if !errors.Is(err, expected) {
+			t.Errorf("Calculating (%s) did not return %v.", expr, expected)
+		}
+	}
+
+	d, err := New("9223372036854775807+1d6")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	_, err = d.Roll()
+	t.Logf("Roll()=%v", err)
+	if !errors.Is(err, ErrOverflow) {
+		t.Errorf("Rolling did not return ErrOverflow.")
+	}
+}
+
+func TestDivideByZeroExclude(t *testing.T) {
+	d, err := New("1d6/(1d3-2)", WithDivideByZero(DivideByZeroExclude))
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	t.Logf("d=%v", d)
+	if err := d.Calculate(); err != nil {
+		t.Errorf("Could not calculate: %v", err)
+	}
+	expected := map[int64]int64{}
+	for outcome := int64(1); outcome <= 6; outcome++ {
+		expected[outcome] = 1
+		expected[-outcome] = 1
+	}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	eq := reflect.DeepEqual(expected, actual)
+	t.Logf("DeepEqual?=%v", eq)
+	if !eq {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+	t.Logf("UndefinedProbability()=%v", d.UndefinedProbability())
+	if d.UndefinedProbability() != float64(1)/3 {
+		t.Errorf("Undefined probability does not match the excluded outcomes.")
+	}
+
+	d, err = New("1d6/0+1d4", WithDivideByZero(DivideByZeroExclude))
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	err = d.Calculate()
+	t.Logf("Calculate()=%v", err)
+	if !errors.Is(err, ErrDivideByZero) {
+		t.Errorf("Calculating an expression with no defined outcomes did not return ErrDivideByZero.")
+	}
+}
+
+func TestDivisionRounding(t *testing.T) {
+	tests := map[string][]int64{
+		"/":  {-3, -2, -2, -1, -1, 0, 0, 0, 1, 1, 2, 2, 3},
+		"//": {-3, -3, -2, -2, -1, -1, 0, 0, 1, 1, 2, 2, 3},
+		"/^": {-3, -2, -2, -1, -1, 0, 0, 1, 1, 2, 2, 3, 3},
+		"/~": {-3, -2, -2, -1, -1, 0, 0, 1, 1, 2, 2, 3, 3},
+	}
+	for op, expected := range tests {
+		o := operatorMap[op]
+		actual := []int64{}
+		for left := int64(-6); left <= 6; left++ {
+			quotient, err := o.Roll(left, 2)
+			if err != nil {
+				t.Errorf("Could not divide %v by 2.", left)
+			}
+			actual = append(actual, quotient)
+		}
+		t.Logf("%s expected=%v", op, expected)
+		t.Logf("%s actual=%v", op, actual)
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Division (%s) does not round as expected.", op)
+		}
+	}
+
+	d, err := New("(1d6-4)//2")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	d.Calculate()
+	expected := map[int64]int64{-2: 1, -1: 2, 0: 2, 1: 1}
+	t.Logf("expected=%v", expected)
+	actual := int64Distribution(d.Distribution())
+	t.Logf("actual=%v", actual)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Calculated distribution does not match the control distribution.")
+	}
+}
+
+func TestLargePools(t *testing.T) {
+	tests := map[string]*big.Int{
+		"40d20":     big.NewInt(0).Exp(big.NewInt(20), big.NewInt(40), nil),
+		"10d6*10d6": big.NewInt(0).Exp(big.NewInt(6), big.NewInt(20), nil),
+		"30d10kh3":  big.NewInt(0).Exp(big.NewInt(10), big.NewInt(30), nil),
+	}
+	for expr, expected := range tests {
+		d, err := New(expr)
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		if err := d.Calculate(); err != nil {
+			t.Errorf("Could not calculate (%s): %v", expr, err)
+		}
+		t.Logf("%s Permutations()=%v", expr, d.Permutations())
+		if d.Permutations().Cmp(expected) != 0 {
+			t.Errorf("Permutations of (%s) do not match %v.", expr, expected)
+		}
+		sum := big.NewInt(0)
+		for _, frequency := range *d.Distribution() {
+			if frequency.Sign() <= 0 {
+				t.Errorf("Frequency of (%s) is not positive.", expr)
+			}
+			sum.Add(sum, frequency)
+		}
+		if sum.Cmp(expected) != 0 {
+			t.Errorf("Distribution of (%s) does not sum to its permutations.", expr)
+		}
+	}
+}
+
+func TestExactProbabilities(t *testing.T) {
+	d, err := New("3d6")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	if err := d.Calculate(); err != nil {
+		t.Errorf("Could not calculate: %v", err)
+	}
+	exact := *d.ExactProbabilities()
+	t.Logf("expected: 1/216 actual: %s", exact[3].RatString())
+	if exact[3].Cmp(big.NewRat(1, 216)) != 0 {
+		t.Errorf("Exact probability of 3 does not match.")
+	}
+	t.Logf("expected: 1/8 actual: %s", exact[10].RatString())
+	if exact[10].Cmp(big.NewRat(1, 8)) != 0 {
+		t.Errorf("Exact probability of 10 does not match.")
+	}
+	cdf := *d.ExactCDF()
+	t.Logf("expected: 1/2 actual: %s", cdf[10].RatString())
+	if cdf[10].Cmp(big.NewRat(1, 2)) != 0 {
+		t.Errorf("Exact CDF of 10 does not match.")
+	}
+	t.Logf("expected: 1 actual: %s", cdf[18].RatString())
+	if cdf[18].Cmp(big.NewRat(1, 1)) != 0 {
+		t.Errorf("Exact CDF of 18 does not match.")
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	d, err := New("3d6")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	if err := d.Calculate(); err != nil {
+		t.Errorf("Could not calculate: %v", err)
+	}
+	t.Logf("expected: 10.5 actual: %v", d.Mean())
+	if d.Mean() != 10.5 {
+		t.Errorf("Mean does not match.")
+	}
+	t.Logf("expected: 8.75 actual: %v", d.Variance())
+	if d.Variance() != 8.75 {
+		t.Errorf("Variance does not match.")
+	}
+	t.Logf("expected: %v actual: %v", math.Sqrt(8.75), d.StdDev())
+	if d.StdDev() != math.Sqrt(8.75) {
+		t.Errorf("Standard deviation does not match.")
+	}
+	t.Logf("expected: 0 actual: %v", d.Skewness())
+	if d.Skewness() != 0 {
+		t.Errorf("Skewness does not match.")
+	}
+	// Kurtosis of the sum of n dice is 3 + (3/n) * (-6(S^2+1)/(5(S^2-1))); for 3d6 that is 3 - 0.4 * 37/35.
+	kurtosis := 3 - 0.4*37.0/35.0
+	t.Logf("expected: %v actual: %v", kurtosis, d.Kurtosis())
+	if math.Abs(d.Kurtosis()-kurtosis) > 1e-12 {
+		t.Errorf("Kurtosis does not match.")
+	}
+	t.Logf("expected: [10 11] actual: %v", *d.Mode())
+	if !reflect.DeepEqual(*d.Mode(), []int64{10, 11}) {
+		t.Errorf("Mode does not match.")
+	}
+	t.Logf("expected: 10 actual: %v", d.Median())
+	if d.Median() != 10 {
+		t.Errorf("Median does not match.")
+	}
+}
+
+func TestCumulative(t *testing.T) {
+	d, err := New("2d6")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	if err := d.Calculate(); err != nil {
+		t.Errorf("Could not calculate: %v", err)
+	}
+	tests := map[string][]float64{
+		"AtLeast(10)":    {d.AtLeast(10), 6.0 / 36.0},
+		"AtLeast(2)":     {d.AtLeast(2), 1},
+		"AtMost(4)":      {d.AtMost(4), 6.0 / 36.0},
+		"AtMost(1)":      {d.AtMost(1), 0},
+		"Between(6,8)":   {d.Between(6, 8), 16.0 / 36.0},
+		"Between(8,6)":   {d.Between(8, 6), 0},
+		"CDF()[7]":       {(*d.CDF())[7], 21.0 / 36.0},
+		"Survival()[7]":  {(*d.Survival())[7], 21.0 / 36.0},
+		"Survival()[12]": {(*d.Survival())[12], 1.0 / 36.0},
+	}
+	for name, values := range tests {
+		t.Logf("%s expected: %v actual: %v", name, values[1], values[0])
+		if math.Abs(values[0]-values[1]) > 1e-12 {
+			t.Errorf("%s does not match.", name)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	d, err := New("2d6")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	if err := d.Calculate(); err != nil {
+		t.Errorf("Could not calculate: %v", err)
+	}
+	// The CDF of 2d6 is 1,3,6,10,15,21,26,30,33,35,36 over 36.
+	tests := map[float64]int64{
+		0:        2,
+		1.0 / 36: 2,
+		0.05:     3,
+		0.5:      7,
+		0.65:     8,
+		0.9:      10,
+		1:        12,
+		2:        12,
+	}
+	for p, expected := range tests {
+		t.Logf("Quantile(%v) expected: %v actual: %v", p, expected, d.Quantile(p))
+		if d.Quantile(p) != expected {
+			t.Errorf("Quantile(%v) does not match.", p)
+		}
+	}
+	percentiles := *d.Percentiles(10, 90)
+	t.Logf("expected: [4 10] actual: %v", percentiles)
+	if !reflect.DeepEqual(percentiles, []int64{4, 10}) {
+		t.Errorf("Percentiles do not match.")
+	}
+	for i := 0; i < 100; i++ {
+		if sample := d.Sample(); sample < 2 || sample > 12 {
+			t.Errorf("Sample %v is out of bounds.", sample)
+		}
+	}
+
+	// Quantiles leave out the outcomes excluded by division by zero, as the other statistics do.
+	d, err = New("1d6/(1d3-2)", WithDivideByZero(DivideByZeroExclude))
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	if err := d.Calculate(); err != nil {
+		t.Errorf("Could not calculate: %v", err)
+	}
+	// The outcomes are -6 to -1 and 1 to 6, each once out of 12.
+	t.Logf("Median() expected: -1 actual: %v", d.Median())
+	if d.Median() != -1 {
+		t.Errorf("Median() does not match.")
+	}
+	t.Logf("Quantile(0.9) expected: 5 actual: %v", d.Quantile(0.9))
+	if d.Quantile(0.9) != 5 {
+		t.Errorf("Quantile(0.9) does not match.")
+	}
+}
+
+func TestComparison(t *testing.T) {
+	tests := map[string]map[int64]int64{
+		"1d20+5>=1d20+3": {0: 153, 1: 247},
+		"1d6==1d6":       {0: 30, 1: 6},
+		"(1d6)!=1d6":     {0: 6, 1: 30},
+		"(1d6)>3":        {0: 3, 1: 3},
+		"(1d6)<=2":       {0: 4, 1: 2},
+		"2*(1d4<2)+1":    {1: 3, 3: 1},
+	}
+	for expr, expected := range tests {
+		d, err := New(expr)
+		if err != nil {
+			t.Errorf("Could not create new instance (%s): %v", expr, err)
+			continue
+		}
+		if err := d.Calculate(); err != nil {
+			t.Errorf("Could not calculate (%s): %v", expr, err)
+			continue
+		}
+		actual := int64Distribution(d.Distribution())
+		t.Logf("%s expected=%v actual=%v", expr, expected, actual)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Distribution of (%s) does not match.", expr)
+		}
+		for i := 0; i < 100; i++ {
+			roll, err := d.Roll()
+			if err != nil {
+				t.Errorf("Could not roll (%s): %v", expr, err)
+			}
+			if _, ok := expected[roll]; !ok {
+				t.Errorf("Roll of (%s) returned %v, which is not an outcome.", expr, roll)
+			}
+		}
+	}
+}
+
+func TestVersus(t *testing.T) {
+	a, err := New("1d20+5")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	b, err := New("1d20+3")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	opposed, err := Versus(a, b)
+	if err != nil {
+		t.Errorf("Could not compare: %v", err)
+	}
+	tests := map[string][]float64{
+		"Win()":  {opposed.Win(), 229.0 / 400.0},
+		"Tie()":  {opposed.Tie(), 18.0 / 400.0},
+		"Lose()": {opposed.Lose(), 153.0 / 400.0},
+	}
+	for name, values := range tests {
+		t.Logf("%s expected: %v actual: %v", name, values[1], values[0])
+		if math.Abs(values[0]-values[1]) > 1e-12 {
+			t.Errorf("%s does not match.", name)
+		}
+	}
+	margin := opposed.Margin()
+	t.Logf("expected: -17..21 actual: %v..%v", margin.Min(), margin.Max())
+	if margin.Min() != -17 || margin.Max() != 21 {
+		t.Errorf("Margin bounds do not match.")
+	}
+	t.Logf("expected: 20 actual: %v", (*margin.Distribution())[2])
+	if (*margin.Distribution())[2].Int64() != 20 {
+		t.Errorf("Margin distribution does not match.")
+	}
+	roll, err := margin.Roll()
+	if err != nil {
+		t.Errorf("Could not roll the margin: %v", err)
+	}
+	if roll < -17 || roll > 21 {
+		t.Errorf("Rolled margin %v outside of bounds.", roll)
+	}
+}
+
+func TestRollSeeded(t *testing.T) {
+	rolls := [][]int64{}
+	for i := 0; i < 2; i++ {
+		d, err := New("4d6kh3+1d20!", WithSeed(42))
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		sequence := []int64{}
+		for j := 0; j < 20; j++ {
+			roll, err := d.Roll()
+			if err != nil {
+				t.Errorf("Could not roll the expression.")
+			}
+			sequence = append(sequence, roll)
+		}
+		rolls = append(rolls, sequence)
+	}
+	t.Logf("expected=%v actual=%v", rolls[0], rolls[1])
+	if !reflect.DeepEqual(rolls[0], rolls[1]) {
+		t.Errorf("Seeded rolls do not repeat.")
+	}
+
+	d, err := New("3d6")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	first, _ := d.ParsedExpression().RollWith(rand.NewSource(7))
+	second, _ := d.ParsedExpression().RollWith(rand.NewSource(7))
+	t.Logf("expected=%v actual=%v", first, second)
+	if first != second {
+		t.Errorf("Rolls with the same source do not repeat.")
+	}
+	for i := 0; i < 100; i++ {
+		roll, err := d.ParsedExpression().RollWith(CryptoSource{})
+		if err != nil {
+			t.Errorf("Could not roll the expression.")
+		}
+		if roll < 3 || roll > 18 {
+			t.Errorf("Rolled value %v outside of bounds.", roll)
+		}
+	}
+}
+
+func TestRollDetailed(t *testing.T) {
+	tests := []string{"3d6+2", "4d6kh3", "1d6!+(1d4r1*2)", "5d10>=8f1", "mid20", "3d6!p", "1d20+5>=1d20+3"}
+	for _, expr := range tests {
+		d, err := New(expr, WithSeed(3))
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		result, err := d.RollDetailed()
+		if err != nil {
+			t.Errorf("Could not roll (%s): %v", expr, err)
+		}
+		t.Logf("%s: %s", expr, result)
+
+		// The same seed must give the same total from Roll.
+		d, _ = New(expr, WithSeed(3))
+		roll, _ := d.Roll()
+		if roll != result.Total {
+			t.Errorf("Total of (%s) does not match Roll; %v != %v.", expr, result.Total, roll)
+		}
+	}
+
+	d, err := New("4d6kh3+2")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	result, err := d.RollDetailed()
+	if err != nil {
+		t.Errorf("Could not roll the expression.")
+	}
+	dice := result.Left.Left.Dice
+	kept, lowest := int64(0), int64(0)
+	for _, die := range dice.Dice {
+		if die.Dropped {
+			lowest = die.Total
+			continue
+		}
+		kept += die.Total
+	}
+	t.Logf("dice=%v kept=%v total=%v", repr.String(dice.Dice), kept, dice.Total)
+	if kept != dice.Total || len(dice.Dice) != 4 || result.Total != kept+2 {
+		t.Errorf("Kept dice do not match the total.")
+	}
+	for _, die := range dice.Dice {
+		if !die.Dropped && die.Total < lowest {
+			t.Errorf("A kept die is lower than the dropped die.")
+		}
+	}
+
+	die := &DieResult{Rolls: []int64{6, 3}, Rerolled: []int64{1}, Exploded: true, Dropped: true}
+	t.Logf("expected: 1r6!3d actual: %s", die)
+	if die.String() != "1r6!3d" {
+		t.Errorf("Die transcript does not match.")
+	}
+	die = &DieResult{Rolls: []int64{6}, Exploded: true}
+	t.Logf("expected: 6! actual: %s", die)
+	if die.String() != "6!" {
+		t.Errorf("Die transcript does not match.")
+	}
+	result = &ExpressionResult{
+		SumResult: SumResult{
+			Left:  &TermResult{Left: &AtomResult{Dice: &DiceResult{Roll: DiceRoll("3d6"), Dice: []*DieResult{{Rolls: []int64{4}}, {Rolls: []int64{2}}, {Rolls: []int64{6}}}, Total: 12}, Total: 12}, Total: 12},
+			Right: []*OpTermResult{{Operator: OpAdd, Term: &TermResult{Left: &AtomResult{Modifier: &[]int64{2}[0], Total: 2}, Total: 2}}},
+			Value: 14,
+		},
+		Total: 14,
+	}
+	t.Logf("expected: 3d6 [4,2,6] + 2 = 12 + 2 = 14 actual: %s", result)
+	if result.String() != "3d6 [4,2,6] + 2 = 12 + 2 = 14" {
+		t.Errorf("Transcript does not match.")
+	}
+
+	// With a Comparison, each side keeps its own Value, and the Total is the result of the Comparison.
+	d, err = New("1d6+10 > 1d4")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	result, err = d.RollDetailed()
+	if err != nil {
+		t.Errorf("Could not roll the expression.")
+	}
+	t.Logf("Value=%v Comparison.Value=%v Total=%v", result.Value, result.Comparison.Value, result.Total)
+	if result.Value < 11 || result.Comparison.Value > 4 || result.Total != 1 {
+		t.Errorf("Values of the compared sides do not match the transcript %s.", result)
+	}
+}
+
+func TestRollMany(t *testing.T) {
+	d, err := New("2d6", WithSeed(1))
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	counts, err := d.RollMany(10000)
+	if err != nil {
+		t.Errorf("Could not roll the expression.")
+	}
+	t.Logf("counts=%v", *counts)
+	total := int64(0)
+	for outcome, count := range *counts {
+		if outcome < 2 || outcome > 12 {
+			t.Errorf("Rolled value %v outside of bounds.", outcome)
+		}
+		total += count
+	}
+	t.Logf("expected: 10000 actual: %v", total)
+	if total != 10000 {
+		t.Errorf("Counts do not sum to the number of rolls.")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []string{"3d6", "4dF", "4d6kh3", "2d6r1", "1d6!", "3d6!o2", "4d6!dl1", "5d10>=8f1", "mid20", "1d20+5>=1d20+3", "1d6!!+1d4!p", "3d{1,1,2,3,5,8}"}
+	for _, expr := range tests {
+		d, err := New(expr, WithSeed(5), WithExplodeDepth(6))
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		chiSquare, pValue, err := d.Verify(20000)
+		if err != nil {
+			t.Errorf("Could not verify (%s): %v", expr, err)
+		}
+		t.Logf("%s chiSquare=%v pValue=%v", expr, chiSquare, pValue)
+		if pValue < 0.001 {
+			t.Errorf("Rolls of (%s) do not follow the distribution.", expr)
+		}
+	}
+
+	// At the default depth, rolls of several exploding dice are often cut off with a total inside the distribution.
+	for _, expr := range []string{"2d6!", "4d6!kh3"} {
+		d, err := New(expr, WithSeed(5))
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		chiSquare, pValue, err := d.Verify(20000)
+		if err != nil {
+			t.Errorf("Could not verify (%s): %v", expr, err)
+		}
+		t.Logf("%s chiSquare=%v pValue=%v", expr, chiSquare, pValue)
+		if pValue < 0.001 {
+			t.Errorf("Rolls of (%s) do not follow the distribution.", expr)
+		}
+	}
+
+	for _, samples := range []int{0, -5} {
+		d, err := New("3d6")
+		if err != nil {
+			t.Errorf("Could not create new instance.")
+		}
+		_, _, err = d.Verify(samples)
+		t.Logf("Verify(%d)=%v", samples, err)
+		if !errors.Is(err, ErrInvalidSamples) {
+			t.Errorf("Verify(%d) did not return ErrInvalidSamples.", samples)
+		}
+	}
+
+	d, err := New("1d6/(1d3-2)", WithSeed(5), WithDivideByZero(DivideByZeroExclude))
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	chiSquare, pValue, err := d.Verify(20000)
+	t.Logf("1d6/(1d3-2) chiSquare=%v pValue=%v", chiSquare, pValue)
+	if err != nil || pValue < 0.001 {
+		t.Errorf("Rolls of (1d6/(1d3-2)) do not follow the distribution.")
+	}
+}
+
+func TestGammaQ(t *testing.T) {
+	for _, x := range []float64{0.1, 1, 2.5, 10, 40} {
+		// With two degrees of freedom the chi-square p-value is exp(-x/2); with one it is erfc(sqrt(x/2)).
+		if actual, expected := gammaQ(1, x/2), math.Exp(-x/2); math.Abs(actual-expected) > 1e-12 {
+			t.Errorf("gammaQ(1, %v) expected: %v actual: %v", x/2, expected, actual)
+		}
+		if actual, expected := gammaQ(0.5, x/2), math.Erfc(math.Sqrt(x/2)); math.Abs(actual-expected) > 1e-12 {
+			t.Errorf("gammaQ(0.5, %v) expected: %v actual: %v", x/2, expected, actual)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []string{
+		"2d6+3",
+		"2d6 + 3",
+		"  4d6kh3  *  ( 1d4 - 1 )  ",
+		"2d6 [fire] + 1d4 [cold]",
+		"(1d6+1) [ bonus ] * 2 # doubled on a crit",
+		"1d20+5 >= 1d20+3",
+		"3D6 // 2 + 1d10!>=9 - 5d10>=8f1 /~ 3",
+		"1d8 [slashing]+2d6[fire]",
+	}
+	for _, expr := range tests {
+		d, err := New(expr)
+		if err != nil {
+			t.Errorf("Could not create new instance (%s): %v", expr, err)
+			continue
+		}
+		s := d.ParsedExpression().String()
+		again, err := New(s)
+		if err != nil {
+			t.Errorf("Could not create new instance from String() (%s): %v", s, err)
+			continue
+		}
+		expected := repr.String(d.ParsedExpression())
+		actual := repr.String(again.ParsedExpression())
+		t.Logf("%q -> %q", expr, s)
+		if actual != expected {
+			t.Errorf("Parsed String() of (%s) does not match; expected=%v actual=%v", expr, expected, actual)
+		}
+		if again.ParsedExpression().String() != s {
+			t.Errorf("String() of (%s) is not stable.", expr)
+		}
+	}
+
+	d, err := New("2d6 [ fire ] + 1")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	label := d.ParsedExpression().Left.Left.Label
+	t.Logf("expected: fire actual: %v", repr.String(label))
+	if label == nil || *label != "fire" {
+		t.Errorf("Label does not match.")
+	}
+}
+
+func TestImplicitCountPercentile(t *testing.T) {
+	tests := map[string]string{
+		"d20":      "1d20",
+		"d6+d4":    "1d6+1d4",
+		"dF":       "1dF",
+		"d%":       "1d100",
+		"2d%kh1":   "2d100kh1",
+		"d6!":      "1d6!",
+		"mid%":     "mid100",
+		"d{1,2,3}": "1d{1,2,3}",
+	}
+	for expr, equivalent := range tests {
+		d, err := New(expr)
+		if err != nil {
+			t.Errorf("Could not create new instance (%s): %v", expr, err)
+			continue
+		}
+		e, err := New(equivalent)
+		if err != nil {
+			t.Errorf("Could not create new instance (%s): %v", equivalent, err)
+			continue
+		}
+		if err := d.Calculate(); err != nil {
+			t.Errorf("Could not calculate (%s): %v", expr, err)
+		}
+		if err := e.Calculate(); err != nil {
+			t.Errorf("Could not calculate (%s): %v", equivalent, err)
+		}
+		t.Logf("%s Permutations()=%v %s Permutations()=%v", expr, d.Permutations(), equivalent, e.Permutations())
+		if !reflect.DeepEqual(int64Distribution(d.Distribution()), int64Distribution(e.Distribution())) {
+			t.Errorf("Distribution of (%s) does not match (%s).", expr, equivalent)
+		}
+	}
+
+	die := &DieResult{Rolls: []int64{47, 100, 5, 40}, Percentile: true}
+	t.Logf("expected: 47(40+7)!100(00+0)!5(00+5)!40(40+0) actual: %s", die)
+	if die.String() != "47(40+7)!100(00+0)!5(00+5)!40(40+0)" {
+		t.Errorf("Percentile transcript does not match.")
+	}
+	d, err := New("d%", WithSeed(9))
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	result, err := d.RollDetailed()
+	if err != nil {
+		t.Errorf("Could not roll the expression.")
+	}
+	t.Logf("d%%: %s", result)
+	if !result.Left.Left.Dice.Dice[0].Percentile {
+		t.Errorf("Percentile die is not marked.")
+	}
+}
+
+func TestUnary(t *testing.T) {
+	tests := map[string]map[int64]int64{
+		"-2+1d6":     {-1: 1, 0: 1, 1: 1, 2: 1, 3: 1, 4: 1},
+		"1d6*-1":     {-6: 1, -5: 1, -4: 1, -3: 1, -2: 1, -1: 1},
+		"-(1d4)":     {-4: 1, -3: 1, -2: 1, -1: 1},
+		"+3 - -2":    {5: 1},
+		"--1d2":      {1: 1, 2: 1},
+		"-1d2 + 1d2": {-1: 1, 0: 2, 1: 1},
+	}
+	for expr, expected := range tests {
+		d, err := New(expr)
+		if err != nil {
+			t.Errorf("Could not create new instance (%s): %v", expr, err)
+			continue
+		}
+		if err := d.Calculate(); err != nil {
+			t.Errorf("Could not calculate (%s): %v", expr, err)
+			continue
+		}
+		actual := int64Distribution(d.Distribution())
+		t.Logf("%s expected=%v actual=%v", expr, expected, actual)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Distribution of (%s) does not match.", expr)
+		}
+		for i := 0; i < 50; i++ {
+			roll, err := d.Roll()
+			if err != nil {
+				t.Errorf("Could not roll (%s): %v", expr, err)
+			}
+			if _, ok := expected[roll]; !ok {
+				t.Errorf("Roll of (%s) returned %v, which is not an outcome.", expr, roll)
+			}
+		}
+		again, err := New(d.ParsedExpression().String())
+		if err != nil {
+			t.Errorf("Could not create new instance from String() (%s): %v", d.ParsedExpression().String(), err)
+			continue
+		}
+		if repr.String(again.ParsedExpression()) != repr.String(d.ParsedExpression()) {
+			t.Errorf("Parsed String() of (%s) does not match.", expr)
+		}
+	}
+}
+
+func TestOrderStatistic(t *testing.T) {
+	// bruteForce - Count every roll of count dice with the faces given, by the order-th lowest die.
+	bruteForce := func(faces []int64, count int, order int) map[int64]int64 {
+		ret := map[int64]int64{}
+		rolls := make([]int64, count)
+		var visit func(i int)
+		visit = func(i int) {
+			if i == count {
+				sorted := append([]int64{}, rolls...)
+				sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+				ret[sorted[order-1]]++
+				return
+			}
+			for _, face := range faces {
+				rolls[i] = face
+				visit(i + 1)
+			}
+		}
+		visit(0)
+		return ret
+	}
+	d6 := []int64{1, 2, 3, 4, 5, 6}
+	// Rerolling a 1 once leaves a 1 on one of 36 rolls, and each other face on 7.
+	rerolled := []int64{1}
+	for face := int64(2); face <= 6; face++ {
+		for i := 0; i < 7; i++ {
+			rerolled = append(rerolled, face)
+		}
+	}
+	tests := map[string]map[int64]int64{
+		"3d6o1":         bruteForce(d6, 3, 1),
+		"3d6o2":         bruteForce(d6, 3, 2),
+		"3d6o3":         bruteForce(d6, 3, 3),
+		"mid6":          bruteForce(d6, 3, 2),
+		"5d6o4":         bruteForce(d6, 5, 4),
+		"3d20m2":        bruteForce([]int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}, 3, 2),
+		"4d{1,1,2,5}o2": bruteForce([]int64{1, 1, 2, 5}, 4, 2),
+		"4dFm3":         bruteForce([]int64{-1, 0, 1}, 4, 3),
+		"2d6r1o1":       bruteForce(rerolled, 2, 1),
+	}
+	for expr, expected := range tests {
+		d, err := New(expr)
+		if err != nil {
+			t.Errorf("Could not create new instance (%s): %v", expr, err)
+			continue
+		}
+		if err := d.Calculate(); err != nil {
+			t.Errorf("Could not calculate (%s): %v", expr, err)
+			continue
+		}
+		actual := int64Distribution(d.Distribution())
+		t.Logf("%s expected=%v actual=%v", expr, expected, actual)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Distribution of (%s) does not match.", expr)
+		}
+		for i := 0; i < 50; i++ {
+			roll, err := d.Roll()
+			if err != nil {
+				t.Errorf("Could not roll (%s): %v", expr, err)
+			}
+			if _, ok := expected[roll]; !ok {
+				t.Errorf("Roll of (%s) returned %v, which is not an outcome.", expr, roll)
+			}
+		}
+	}
+
+	for _, expr := range []string{"3d6o0", "3d6o4", "mid6o1", "mid6kh1"} {
+		_, err := New(expr)
+		t.Logf("New(%q)=%v", expr, err)
+		if !errors.Is(err, ErrInvalidDice) {
+			t.Errorf("Invalid dice roll (%s) did not return ErrInvalidDice.", expr)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	advantage := map[int64]int64{}
+	for k := int64(1); k <= 20; k++ {
+		advantage[k] = 2*k - 1
+	}
+	tests := map[string]map[int64]int64{
+		"max(1d20, 1d20)":      advantage,
+		"MAX(d20,d20)":         advantage,
+		"min(1d4, 1d4, 2)":     {1: 7, 2: 9},
+		"max(1, 1d6-2)":        {1: 3, 2: 1, 3: 1, 4: 1},
+		"abs(1d6-1d6)":         {0: 6, 1: 10, 2: 8, 3: 6, 4: 4, 5: 2},
+		"clamp(2d6, 4, 10)":    {4: 6, 5: 4, 6: 5, 7: 6, 8: 5, 9: 4, 10: 6},
+		"floordiv(1d6-4, 2)":   {-2: 1, -1: 2, 0: 2, 1: 1},
+		"ceildiv(1d4, 2)":      {1: 2, 2: 2},
+		"rounddiv(1d6, 4)":     {0: 1, 1: 4, 2: 1},
+		"max(1d4, 1d4) [adv]":  {1: 1, 2: 3, 3: 5, 4: 7},
+		"2 * abs(-(1d2)) - 1":  {1: 1, 3: 1},
+		"max((1d6)>3, 1d2==1)": {0: 3, 1: 9},
+	}
+	for expr, expected := range tests {
+		d, err := New(expr)
+		if err != nil {
+			t.Errorf("Could not create new instance (%s): %v", expr, err)
+			continue
+		}
+		if err := d.Calculate(); err != nil {
+			t.Errorf("Could not calculate (%s): %v", expr, err)
+			continue
+		}
+		actual := int64Distribution(d.Distribution())
+		t.Logf("%s expected=%v actual=%v", expr, expected, actual)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Distribution of (%s) does not match.", expr)
+		}
+		for i := 0; i < 50; i++ {
+			roll, err := d.Roll()
+			if err != nil {
+				t.Errorf("Could not roll (%s): %v", expr, err)
+			}
+			if _, ok := expected[roll]; !ok {
+				t.Errorf("Roll of (%s) returned %v, which is not an outcome.", expr, roll)
+			}
+		}
+		again, err := New(d.ParsedExpression().String())
+		if err != nil {
+			t.Errorf("Could not create new instance from String() (%s): %v", d.ParsedExpression().String(), err)
+			continue
+		}
+		if repr.String(again.ParsedExpression()) != repr.String(d.ParsedExpression()) {
+			t.Errorf("Parsed String() of (%s) does not match.", expr)
+		}
+	}
+
+	for _, expr := range []string{"foo(1d6)", "abs(1, 2)", "clamp(1d6, 2)", "max(0d6)"} {
+		_, err := New(expr)
+		t.Logf("New(%q)=%v", expr, err)
+		if !errors.Is(err, ErrInvalidFunction) && !errors.Is(err, ErrInvalidDice) {
+			t.Errorf("Invalid function call (%s) did not return an error.", expr)
+		}
+	}
+
+	// Many arguments are folded pairwise; the highest of eight 3d6 is 18 unless none of them is.
+	d, err := New("max(3d6, 3d6, 3d6, 3d6, 3d6, 3d6, 3d6, 3d6)")
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	if err := d.Calculate(); err != nil {
+		t.Errorf("Could not calculate: %v", err)
+	}
+	permutations := big.NewInt(0).Exp(big.NewInt(216), big.NewInt(8), nil)
+	expected := big.NewInt(0).Sub(permutations, big.NewInt(0).Exp(big.NewInt(215), big.NewInt(8), nil))
+	t.Logf("expected: %v actual: %v", expected, (*d.Distribution())[18])
+	if d.Permutations().Cmp(permutations) != 0 || (*d.Distribution())[18].Cmp(expected) != 0 {
+		t.Errorf("Distribution of many arguments does not match.")
+	}
+
+	d, err = New("floordiv(1d6, 1d3-2)", WithDivideByZero(DivideByZeroExclude))
+	if err != nil {
+		t.Errorf("Could not create new instance.")
+	}
+	if err := d.Calculate(); err != nil {
+		t.Errorf("Could not calculate: %v", err)
+	}
+	t.Logf("expected: %v actual: %v", 1.0/3.0, d.UndefinedProbability())
+	if math.Abs(d.UndefinedProbability()-1.0/3.0) > 1e-12 {
+		t.Errorf("Undefined probability does not match.")
+	}
+}
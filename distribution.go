@@ -1,132 +1,319 @@
 package diceprob
 
 import (
-	"math"
+	"errors"
+	"fmt"
 	"math/big"
-	"regexp"
-	"strconv"
-	"strings"
+	"sort"
 )
 
+// frequencies - Frequency of each outcome, along with the frequency of outcomes cut off or excluded from the distribution.
+type frequencies struct {
+	outcomes  map[int64]*big.Int // Frequency of each outcome.
+	truncated *big.Int           // Frequency of outcomes cut off by the explosion depth.
+	undefined *big.Int           // Frequency of outcomes excluded by division by zero.
+}
+
+// newFrequencies - Create an empty set of frequencies.
+func newFrequencies() *frequencies {
+	return &frequencies{
+		outcomes:  map[int64]*big.Int{},
+		truncated: big.NewInt(0),
+		undefined: big.NewInt(0),
+	}
+}
+
+// add - Add to the frequency of an outcome.
+func (f *frequencies) add(outcome int64, frequency *big.Int) {
+	if f.outcomes[outcome] == nil {
+		f.outcomes[outcome] = big.NewInt(0)
+	}
+	f.outcomes[outcome].Add(f.outcomes[outcome], frequency)
+}
+
+// defined - Total frequency of the outcomes in the distribution.
+func (f *frequencies) defined() *big.Int {
+	ret := big.NewInt(0)
+	for _, frequency := range f.outcomes {
+		ret.Add(ret, frequency)
+	}
+	return ret
+}
+
+// untruncated - Total frequency, including outcomes excluded by division by zero, but not those cut off.
+func (f *frequencies) untruncated() *big.Int {
+	ret := f.defined()
+	return ret.Add(ret, f.undefined)
+}
+
+// total - Total frequency, including outcomes cut off or excluded from the distribution.
+func (f *frequencies) total() *big.Int {
+	ret := f.untruncated()
+	return ret.Add(ret, f.truncated)
+}
+
 // Distribution - Determine the outcomes' distribution for the Expression; top-level of the recursive distribution functions.
-func (e *Expression) Distribution() *map[int64]int64 {
-	left := e.Left.distribution()
-	for _, right := range e.Right {
-		left = right.Operator.distribution(left, right.Term.distribution())
+func (e *Expression) Distribution() (*map[int64]*big.Int, error) {
+	calculated, err := e.distribution(defaultOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &calculated.outcomes, nil
+}
+
+// distribution - Determine the outcomes' frequencies for the Expression; part of the recursive distribution functions.
+func (e *Expression) distribution(o *options) (*frequencies, error) {
+	left, err := e.Sum.distribution(o)
+	if err != nil {
+		return nil, err
+	}
+	if e.Comparison != nil {
+		compared, err := e.Comparison.Sum.distribution(o)
+		if err != nil {
+			return nil, err
+		}
+		return e.Comparison.Operator.distribution(left, compared, o)
 	}
-	return left
+	return left, nil
+}
+
+// distribution - Determine the outcomes' frequencies for the Sum; part of the recursive distribution functions.
+func (s *Sum) distribution(o *options) (*frequencies, error) {
+	left, err := s.Left.distribution(o)
+	if err != nil {
+		return nil, err
+	}
+	for _, right := range s.Right {
+		term, err := right.Term.distribution(o)
+		if err != nil {
+			return nil, err
+		}
+		left, err = right.Operator.distribution(left, term, o)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
 }
 
 // Distribution - Determine the outcomes' distribution around an Operator; part of the recursive distribution functions.
-func (o Operator) distribution(left, right *map[int64]int64) *map[int64]int64 {
-	combined := map[int64]int64{}
+func (o Operator) distribution(left, right *frequencies, opts *options) (*frequencies, error) {
+	return combineDistribution(left, right, o.Roll, opts)
+}
+
+// combineDistribution - Determine the outcomes' frequencies of combining every outcome of left with every outcome of right.
+func combineDistribution(left, right *frequencies, combine func(left, right int64) (int64, error), opts *options) (*frequencies, error) {
+	combined := newFrequencies()
 
-	for outcome1, freq1 := range *left {
-		for outcome2, freq2 := range *right {
-			outcomeNew := int64(0)
-			switch o {
-			case OpMul:
-				outcomeNew = outcome1 * outcome2
-			case OpDiv:
-				outcomeNew = outcome1 / outcome2
-			case OpAdd:
-				outcomeNew = outcome1 + outcome2
-			case OpSub:
-				outcomeNew = outcome1 - outcome2
+	for outcome1, freq1 := range left.outcomes {
+		for outcome2, freq2 := range right.outcomes {
+			outcomeNew, err := combine(outcome1, outcome2)
+			if errors.Is(err, ErrDivideByZero) && opts.divideByZero == DivideByZeroExclude {
+				// Exclude the undefined outcome; it is counted with the other excluded outcomes below.
+				continue
 			}
-			combined[outcomeNew] = combined[outcomeNew] + (freq1 * freq2)
+			if err != nil {
+				return nil, err
+			}
+			combined.add(outcomeNew, big.NewInt(0).Mul(freq1, freq2))
 		}
 	}
 
-	return &combined
+	// Any combination involving an excluded outcome is itself excluded...
+	untruncated := big.NewInt(0).Mul(left.untruncated(), right.untruncated())
+	combined.undefined.Sub(untruncated, combined.defined())
+
+	// ...and any combination involving a cut off outcome is itself cut off.
+	total := big.NewInt(0).Mul(left.total(), right.total())
+	combined.truncated.Sub(total, untruncated)
+
+	return combined, nil
 }
 
 // Distribution - Determine the outcomes' distribution for the Term; part of the recursive distribution functions.
-func (t *Term) distribution() *map[int64]int64 {
-	left := t.Left.distribution()
+func (t *Term) distribution(o *options) (*frequencies, error) {
+	left, err := t.Left.distribution(o)
+	if err != nil {
+		return nil, err
+	}
 	for _, right := range t.Right {
-		left = right.Operator.distribution(left, right.Atom.distribution())
+		atom, err := right.Atom.distribution(o)
+		if err != nil {
+			return nil, err
+		}
+		left, err = right.Operator.distribution(left, atom, o)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return left
+	return left, nil
 }
 
 // Distribution - Determine the outcomes' distribution for the Atom; part of the recursive distribution functions.
-func (a *Atom) distribution() *map[int64]int64 {
+func (a *Atom) distribution(o *options) (*frequencies, error) {
 	switch {
 	case a.Modifier != nil:
-		return &map[int64]int64{*a.Modifier: 1}
+		ret := newFrequencies()
+		ret.add(*a.Modifier, big.NewInt(1))
+		return ret, nil
 	case a.RollExpr != nil:
-		return a.RollExpr.distribution()
+		return a.RollExpr.distribution(o)
+	case a.Unary != nil:
+		return a.Unary.distribution(o)
+	case a.Call != nil:
+		return a.Call.distribution(o)
 	default:
-		return a.SubExpression.Distribution()
+		return a.SubExpression.distribution(o)
 	}
 }
 
-// Distribution - Determine the outcomes' distribution for the DiceRoll; deepest of the recursive distribution functions.
-func (s *DiceRoll) distribution() *map[int64]int64 {
-	// Convert s to a string.
-	sActual := strings.ToLower(string(*s))
+// distribution - Determine the outcomes' frequencies for the Unary; part of the recursive distribution functions.
+func (u *Unary) distribution(o *options) (*frequencies, error) {
+	atom, err := u.Atom.distribution(o)
+	if err != nil {
+		return nil, err
+	}
+	// Negation subtracts the Atom from zero; plus adds it.
+	zero := newFrequencies()
+	zero.add(0, big.NewInt(1))
+	return u.Operator.distribution(zero, atom, o)
+}
 
-	// Prepare for the distribution.
-	retDist := map[int64]int64{}
-
-	// Prepare a regex to parse the dice roll.
-	re := regexp.MustCompile(`(?P<left>\d+|mi)d(?P<right>\d+|f)`)
-
-	// Parse the roll syntax.
-	m := re.FindStringSubmatch(sActual)
-	left := m[re.SubexpIndex("left")]
-	right := m[re.SubexpIndex("right")]
-
-	// Convert the right side to an integer.
-	rightInt := int64(0)
-	if right == "f" {
-		// Fudge/FATE dice equate to d3.
-		rightInt = 3
-	} else {
-		// Otherwise convert the string to integer.
-		err := error(nil)
-		rightInt, err = strconv.ParseInt(right, 10, 64)
+// distribution - Determine the outcomes' frequencies for the Call, folding its arguments pairwise when the function allows,
+// or over every combination of them; part of the recursive distribution functions.
+func (c *Call) distribution(opts *options) (*frequencies, error) {
+	f, err := c.lookup()
+	if err != nil {
+		return nil, err
+	}
+	args := []*frequencies{}
+	for _, arg := range c.Args {
+		calculated, err := arg.distribution(opts)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
+		args = append(args, calculated)
 	}
 
-	// Determine which kind of roll it is...
-	switch left {
-	case "mi":
-		// "Middle" roll.
+	// Fold the arguments in turn, combining the value so far with each argument.
+	if f.fold != nil {
+		ret := args[0]
+		for i, arg := range args[1:] {
+			i := i + 1
+			ret, err = combineDistribution(ret, arg, func(value, arg int64) (int64, error) { return f.fold(i, value, arg) }, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	}
+
+	// Visit every combination of the arguments' outcomes, applying the function to each.
+	combined := newFrequencies()
+	values := make([]int64, len(args))
+	var visit func(i int, frequency *big.Int) error
+	visit = func(i int, frequency *big.Int) error {
+		if i == len(args) {
+			outcome, err := f.apply(values)
+			if errors.Is(err, ErrDivideByZero) && opts.divideByZero == DivideByZeroExclude {
+				// Exclude the undefined outcome; it is counted with the other excluded outcomes below.
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			combined.add(outcome, frequency)
+			return nil
+		}
+		for outcome, argFrequency := range args[i].outcomes {
+			values[i] = outcome
+			if err := visit(i+1, big.NewInt(0).Mul(frequency, argFrequency)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(0, big.NewInt(1)); err != nil {
+		return nil, err
+	}
 
-		// For each outcome in the set...
-		for outcome := int64(1); outcome <= rightInt; outcome++ {
-			// Calculate the number of combinations giving outcome as the middle value.
-			retDist[outcome] = 1 + (3 * (rightInt - 1)) + (6 * (outcome - 1) * (rightInt - outcome))
+	// Any combination involving an excluded outcome is itself excluded, and any involving a cut off outcome is cut off.
+	untruncated := big.NewInt(1)
+	total := big.NewInt(1)
+	for _, arg := range args {
+		untruncated.Mul(untruncated, arg.untruncated())
+		total.Mul(total, arg.total())
+	}
+	combined.undefined.Sub(untruncated, combined.defined())
+	combined.truncated.Sub(total, untruncated)
+
+	return combined, nil
+}
+
+// Distribution - Determine the outcomes' distribution for the DiceRoll; deepest of the recursive distribution functions.
+func (s *DiceRoll) distribution(o *options) (*frequencies, error) {
+	// Parse the dice roll.
+	spec, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare for the distribution.
+	ret := newFrequencies()
+
+	// The number of sides on each die.
+	rightInt := spec.sides
+
+	// Unless compounding, each explosion adds a die to the pool the dice are kept from.
+	if spec.explode != nil && !spec.compound && (spec.order > 0 || spec.keep < spec.count) {
+		return poolDistribution(spec, o)
+	}
+
+	// Order statistics, including "middle" rolls, take the value of a single die, whatever kind of dice are rolled.
+	if spec.order > 0 {
+		die, err := dieDistribution(spec, o)
+		if err != nil {
+			return nil, err
 		}
+		return orderDistribution(die, spec.count, spec.order)
+	}
 
-		if right == "f" {
-			for i := int64(1); i <= 3; i++ {
-				retDist[i-2] = retDist[i]
+	// Custom, rerolled, exploding or success counting dice are built up from the frequencies of a single die.
+	if spec.custom || spec.reroll != nil || spec.explode != nil || spec.success != nil {
+		die, err := dieDistribution(spec, o)
+		if err != nil {
+			return nil, err
+		}
+		if spec.keep < spec.count {
+			return keepDistribution(die, spec.count, spec.keep, spec.keepHigh)
+		}
+		ret := die
+		for i := int64(2); i <= spec.count; i++ {
+			ret, err = OpAdd.distribution(ret, die, o)
+			if err != nil {
+				return nil, err
 			}
-			delete(retDist, 2)
-			delete(retDist, 3)
 		}
+		return ret, nil
+	}
 
-		break
-	default:
-		// Standard dice roll. Convert left to Int64.
-		leftInt, err := strconv.ParseInt(left, 10, 64)
+	// Determine which kind of roll it is...
+	switch {
+	case spec.keep < spec.count:
+		// Keep/drop roll; only some of the dice count towards the sum.
+		die, err := dieDistribution(spec, o)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
+		return keepDistribution(die, spec.count, spec.keep, spec.keepHigh)
+	default:
+		// Standard dice roll.
+		leftInt := spec.count
 
 		// Save effort if only one die...
 		if leftInt == 1 {
-			for outcome := int64(1); outcome <= rightInt; outcome++ {
-				if right == "f" {
-					retDist[outcome-2] = 1
-				} else {
-					retDist[outcome] = 1
-				}
+			for i := int64(0); i < spec.sides; i++ {
+				ret.add(spec.face(i), big.NewInt(1))
 			}
 			break
 		}
@@ -134,7 +321,10 @@ func (s *DiceRoll) distribution() *map[int64]int64 {
 		// More than 1 die!
 		// Calculate min, max
 		min := leftInt
-		max := leftInt * rightInt
+		max, err := mulInt64(leftInt, rightInt)
+		if err != nil {
+			return nil, err
+		}
 		// And peak around which we will mirror the distribution.
 		peak := (min + max) / 2
 
@@ -145,33 +335,450 @@ func (s *DiceRoll) distribution() *map[int64]int64 {
 			// Determine the ceiling of the sum function.
 			ceiling := (outcome - leftInt) / rightInt
 			// Initialize the frequency.
-			frequency := int64(0)
-			// For 0 to ceiling, sum the frequencies.
+			frequency := big.NewInt(0)
+			// For 0 to ceiling, sum the frequencies; the terms alternate in sign.
 			for i := int64(0); i <= ceiling; i++ {
 				part1 := big.NewInt(0)
 				part2 := big.NewInt(0)
-				frequency = frequency + (int64(math.Pow(-1, float64(i))) *
-					part1.Binomial(leftInt, i).Int64() *
-					part2.Binomial((outcome-(rightInt*i)-1), (leftInt-1)).Int64())
+				part1.Binomial(leftInt, i).Mul(part1, part2.Binomial((outcome-(rightInt*i)-1), (leftInt-1)))
+				if i%2 == 0 {
+					frequency.Add(frequency, part1)
+				} else {
+					frequency.Sub(frequency, part1)
+				}
 			}
 			// Assign the outcome...
-			retDist[outcome] = frequency
+			ret.outcomes[outcome] = frequency
 			// ...and its mirror.
-			retDist[reflected] = frequency
+			ret.outcomes[reflected] = big.NewInt(0).Set(frequency)
 		}
 
 		// If Fudge/FATE dice, adjust the outcomes.
-		if right == "f" {
+		if spec.fudge {
 			for i, j := int64(leftInt*-1), min; i <= leftInt; i, j = i+1, j+1 {
-				retDist[i] = retDist[j]
+				ret.outcomes[i] = ret.outcomes[j]
 			}
 			for i := leftInt + 1; i <= max; i++ {
-				delete(retDist, i)
+				delete(ret.outcomes, i)
 			}
 		}
 
 		break
 	}
 	// Return the distribution.
-	return &retDist
+	return ret, nil
+}
+
+// dieDistribution - Determine the frequencies of a single die of the DiceRoll, after any rerolls and explosions.
+// When counting successes, the frequencies are of the die's score rather than its value.
+func dieDistribution(spec *diceSpec, o *options) (*frequencies, error) {
+	// Frequencies of a single roll of the die.
+	roll := rerollDistribution(spec.allFaces(), spec.reroll, spec.rerollAll)
+	if spec.explode == nil {
+		if spec.success != nil {
+			return scoreDistribution(roll, spec), nil
+		}
+		return roll, nil
+	}
+
+	// Follow the explosions.
+	die, err := explodeDistribution(roll, spec, o.explodeDepth, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compounding dice are scored once, on their total.
+	if spec.success != nil && spec.compound {
+		return scoreDistribution(die, spec), nil
+	}
+	return die, nil
+}
+
+// scoreDistribution - Determine the frequencies of the success score of a die, from the frequencies of its value.
+func scoreDistribution(die *frequencies, spec *diceSpec) *frequencies {
+	ret := newFrequencies()
+	ret.truncated.Set(die.truncated)
+	for value, frequency := range die.outcomes {
+		ret.add(spec.score(value), frequency)
+	}
+	return ret
+}
+
+// rerollDistribution - Determine the frequencies of a single roll of a die with the given faces, rerolling those matching reroll.
+func rerollDistribution(faces []int64, reroll *condition, all bool) *frequencies {
+	ret := newFrequencies()
+
+	// Count the faces, and those which would be rerolled.
+	sides := int64(len(faces))
+	rerolled := int64(0)
+	for _, face := range faces {
+		if reroll != nil && reroll.matches(face) {
+			rerolled++
+		}
+	}
+
+	// For each face...
+	for _, face := range faces {
+		frequency := int64(0)
+		switch {
+		case reroll == nil:
+			// No rerolls; each face is equally likely.
+			frequency = 1
+		case all:
+			// Reroll until the face no longer matches; only the faces which do not match remain, equally likely.
+			if !reroll.matches(face) {
+				frequency = 1
+			}
+		default:
+			// Reroll once; the face is kept if it was rolled without matching, and may also come from any reroll.
+			if !reroll.matches(face) {
+				frequency = sides
+			}
+			frequency = frequency + rerolled
+		}
+		if frequency > 0 {
+			ret.add(face, big.NewInt(frequency))
+		}
+	}
+
+	return ret
+}
+
+// explodeDistribution - Determine the frequencies of a single exploding die, following at most depth explosions.
+// Each roll of the die has the frequencies given, and the offset is subtracted from its face. Unless compounding,
+// each roll is scored separately when counting successes.
+func explodeDistribution(roll *frequencies, spec *diceSpec, depth int64, offset int64) (*frequencies, error) {
+	ret := newFrequencies()
+
+	// Penetrating dice reduce each roll after an explosion by 1.
+	penalty := int64(0)
+	if spec.penetrate {
+		penalty = 1
+	}
+
+	// Frequencies of the die rolled after an explosion; nil once the depth is exhausted.
+	var next *frequencies
+	nextTotal := big.NewInt(1)
+	if depth > 0 {
+		var err error
+		next, err = explodeDistribution(roll, spec, depth-1, penalty)
+		if err != nil {
+			return nil, err
+		}
+		nextTotal = next.total()
+	}
+
+	// For each face...
+	for face, weight := range roll.outcomes {
+		// Determine what the face adds to the die.
+		value, err := subInt64(face, offset)
+		if err != nil {
+			return nil, err
+		}
+		if spec.success != nil && !spec.compound {
+			value = spec.score(value)
+		}
+
+		switch {
+		case !spec.explode.matches(face):
+			// No explosion; the face stands in for every roll that could have followed it.
+			ret.add(value, big.NewInt(0).Mul(weight, nextTotal))
+		case next == nil:
+			// The die explodes, but the depth is exhausted; cut it off.
+			ret.truncated.Add(ret.truncated, weight)
+		default:
+			// The die explodes; add the value to every outcome of the next roll.
+			for outcome, frequency := range next.outcomes {
+				sum, err := addInt64(value, outcome)
+				if err != nil {
+					return nil, err
+				}
+				ret.add(sum, big.NewInt(0).Mul(weight, frequency))
+			}
+			ret.truncated.Add(ret.truncated, big.NewInt(0).Mul(weight, next.truncated))
+		}
+	}
+
+	return ret, nil
+}
+
+// poolState - Dice of a pool which may decide the outcome, with the frequency of the rolls leading to them.
+type poolState struct {
+	best      []int64  // Values of the dice most likely to be kept (or dropped), in keeping (or dropping) order.
+	sum       int64    // Sum of every die in the pool, when dropping dice.
+	frequency *big.Int // Frequency of the rolls leading to this state.
+}
+
+// poolDistribution - Determine the distribution of the dice kept from a pool of exploding dice, following at most depth
+// explosions per die; each explosion adds a die to the pool, which may be kept or dropped like the dice first rolled.
+func poolDistribution(spec *diceSpec, o *options) (*frequencies, error) {
+	// Frequencies of a single roll of the die.
+	roll := rerollDistribution(spec.allFaces(), spec.reroll, spec.rerollAll)
+
+	// Track the dice which may decide the outcome: the lowest order dice for an order statistic, the dice kept, or the
+	// dice dropped along with the sum of the pool.
+	tracked, high := spec.keep, spec.keepHigh
+	switch {
+	case spec.order > 0:
+		tracked, high = spec.order, false
+	case spec.drop:
+		tracked, high = spec.count-spec.keep, !spec.keepHigh
+	}
+
+	// Penetrating dice reduce each roll after an explosion by 1.
+	penalty := int64(0)
+	if spec.penetrate {
+		penalty = 1
+	}
+
+	// add - Add a die of the value to every state, weighted by the face's frequency, into next.
+	add := func(next map[string]*poolState, states map[string]*poolState, value int64, weight *big.Int) error {
+		for _, state := range states {
+			// Insert the value in order, keeping only the tracked dice.
+			best := append([]int64{}, state.best...)
+			i := sort.Search(len(best), func(i int) bool {
+				if high {
+					return best[i] < value
+				}
+				return best[i] > value
+			})
+			best = append(best[:i], append([]int64{value}, best[i:]...)...)
+			if int64(len(best)) > tracked {
+				best = best[:tracked]
+			}
+			sum := int64(0)
+			if spec.drop {
+				var err error
+				sum, err = addInt64(state.sum, value)
+				if err != nil {
+					return err
+				}
+			}
+			frequency := big.NewInt(0).Mul(state.frequency, weight)
+			key := fmt.Sprint(best, sum)
+			if next[key] == nil {
+				next[key] = &poolState{best: best, sum: sum, frequency: frequency}
+				continue
+			}
+			next[key].frequency.Add(next[key].frequency, frequency)
+		}
+		return nil
+	}
+
+	// Every die is given the same total frequency, roll.total() to the power depth+1, as by explodeDistribution.
+	rollTotal := roll.total()
+	truncated := big.NewInt(0)
+
+	// explode - Roll a die into every state, following at most depth more explosions, and return the new states.
+	var explode func(states map[string]*poolState, depth int64, offset int64) (map[string]*poolState, error)
+	explode = func(states map[string]*poolState, depth int64, offset int64) (map[string]*poolState, error) {
+		ret := map[string]*poolState{}
+		remaining := big.NewInt(0).Exp(rollTotal, big.NewInt(depth), nil)
+		for face, weight := range roll.outcomes {
+			// Determine the value of the die.
+			value, err := subInt64(face, offset)
+			if err != nil {
+				return nil, err
+			}
+			if spec.success != nil {
+				value = spec.score(value)
+			}
+
+			switch {
+			case !spec.explode.matches(face):
+				// No explosion; the face stands in for every roll that could have followed it.
+				if err := add(ret, states, value, big.NewInt(0).Mul(weight, remaining)); err != nil {
+					return nil, err
+				}
+			case depth == 0:
+				// The die explodes, but the depth is exhausted; cut it off.
+				for _, state := range states {
+					truncated.Add(truncated, big.NewInt(0).Mul(state.frequency, weight))
+				}
+			default:
+				// The die explodes; add it to the pool, and roll the next die.
+				exploded := map[string]*poolState{}
+				if err := add(exploded, states, value, weight); err != nil {
+					return nil, err
+				}
+				exploded, err = explode(exploded, depth-1, penalty)
+				if err != nil {
+					return nil, err
+				}
+				for key, state := range exploded {
+					if ret[key] == nil {
+						ret[key] = state
+						continue
+					}
+					ret[key].frequency.Add(ret[key].frequency, state.frequency)
+				}
+			}
+		}
+		return ret, nil
+	}
+
+	// Roll each die into the pool; rolls already cut off are scaled to match the rolls which follow.
+	dieTotal := big.NewInt(0).Exp(rollTotal, big.NewInt(o.explodeDepth+1), nil)
+	states := map[string]*poolState{"": {frequency: big.NewInt(1)}}
+	for i := int64(0); i < spec.count; i++ {
+		truncated.Mul(truncated, dieTotal)
+		var err error
+		states, err = explode(states, o.explodeDepth, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Determine the outcome of each state.
+	ret := newFrequencies()
+	ret.truncated.Set(truncated)
+	for _, state := range states {
+		outcome := int64(0)
+		switch {
+		case spec.order > 0:
+			outcome = state.best[len(state.best)-1]
+		case spec.drop:
+			outcome = state.sum
+			for _, value := range state.best {
+				var err error
+				outcome, err = subInt64(outcome, value)
+				if err != nil {
+					return nil, err
+				}
+			}
+		default:
+			for _, value := range state.best {
+				var err error
+				outcome, err = addInt64(outcome, value)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		ret.add(outcome, state.frequency)
+	}
+	return ret, nil
+}
+
+// keepDistribution - Determine the distribution of the sum of the kept dice, keeping the highest (or lowest) keep of count dice.
+func keepDistribution(die *frequencies, count int64, keep int64, high bool) (*frequencies, error) {
+	// Frequency of each value of a single die.
+	weights := die.outcomes
+
+	// Order the values so those that would be kept are visited first.
+	values := make([]int64, 0, len(weights))
+	for value := range weights {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if high {
+			return values[i] > values[j]
+		}
+		return values[i] < values[j]
+	})
+
+	// states[placed] holds the frequencies of each kept sum, once placed dice have been assigned a value.
+	states := make([]*frequencies, count+1)
+	states[0] = newFrequencies()
+	states[0].add(0, big.NewInt(1))
+
+	// For each value, in keeping order...
+	for _, value := range values {
+		next := make([]*frequencies, count+1)
+		for placed := int64(0); placed <= count; placed++ {
+			if states[placed] == nil {
+				continue
+			}
+			for sum, frequency := range states[placed].outcomes {
+				// Assign the value to n of the remaining dice; the first of them (up to keep) are kept.
+				ways := big.NewInt(1)
+				for n := int64(0); placed+n <= count; n++ {
+					kept := minInt64(placed+n, keep) - minInt64(placed, keep)
+					keptSum, err := mulInt64(kept, value)
+					if err != nil {
+						return nil, err
+					}
+					keptSum, err = addInt64(sum, keptSum)
+					if err != nil {
+						return nil, err
+					}
+					if next[placed+n] == nil {
+						next[placed+n] = newFrequencies()
+					}
+					product := big.NewInt(0).Binomial(count-placed, n)
+					product.Mul(product, ways).Mul(product, frequency)
+					next[placed+n].add(keptSum, product)
+					ways.Mul(ways, weights[value])
+				}
+			}
+		}
+		states = next
+	}
+
+	// Every die has been placed; any roll where a die was cut off is itself cut off.
+	ret := states[count]
+	exp := big.NewInt(count)
+	total := big.NewInt(0).Exp(die.total(), exp, nil)
+	ret.truncated.Sub(total, big.NewInt(0).Exp(die.defined(), exp, nil))
+	return ret, nil
+}
+
+// orderDistribution - Determine the distribution of the order-th lowest of count dice, given the frequencies of a single die.
+func orderDistribution(die *frequencies, count int64, order int64) (*frequencies, error) {
+	// Visit the values of a single die from lowest to highest.
+	values := make([]int64, 0, len(die.outcomes))
+	for value := range die.outcomes {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	// atMost - Number of rolls where the order-th lowest die is at most a value, given the frequency at most that value;
+	// at least order of the dice must be at most the value, and the rest above it.
+	defined := die.defined()
+	atMost := func(below *big.Int) *big.Int {
+		ret := big.NewInt(0)
+		above := big.NewInt(0).Sub(defined, below)
+		for j := order; j <= count; j++ {
+			term := big.NewInt(0).Binomial(count, j)
+			term.Mul(term, big.NewInt(0).Exp(below, big.NewInt(j), nil))
+			term.Mul(term, big.NewInt(0).Exp(above, big.NewInt(count-j), nil))
+			ret.Add(ret, term)
+		}
+		return ret
+	}
+
+	// The frequency of each value is the difference of the rolls at most it, and at most the value before.
+	ret := newFrequencies()
+	cumulative := big.NewInt(0)
+	previous := big.NewInt(0)
+	for _, value := range values {
+		cumulative = big.NewInt(0).Add(cumulative, die.outcomes[value])
+		current := atMost(cumulative)
+		if frequency := big.NewInt(0).Sub(current, previous); frequency.Sign() > 0 {
+			ret.add(value, frequency)
+		}
+		previous = current
+	}
+
+	// Any roll where a die was cut off is itself cut off.
+	exp := big.NewInt(count)
+	total := big.NewInt(0).Exp(die.total(), exp, nil)
+	ret.truncated.Sub(total, big.NewInt(0).Exp(defined, exp, nil))
+	return ret, nil
+}
+
+// minInt64 - Return the lesser of two integers.
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt64 - Return the greater of two integers.
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
 }
@@ -1,28 +1,129 @@
 package diceprob
 
 import (
+	"fmt"
+	"math"
+	"math/big"
 	"math/rand"
-	"sort"
-	"time"
+	"regexp"
+	"strings"
+	"sync"
 )
 
+// dieNamePattern - Regular expression matching valid names for registered dice.
+var dieNamePattern = regexp.MustCompile(`^[a-z]+$`)
+
+// registry - Faces of the registered dice, by name.
+var registry = struct {
+	sync.RWMutex
+	dice map[string][]int64
+}{dice: map[string][]int64{}}
+
+// RegisterDie - Register a named die with the given faces, for use in expressions such as "3davg".
+// Names are letters only, and are not case sensitive; registering a name again replaces its faces.
+func RegisterDie(name string, faces []int64) error {
+	name = strings.ToLower(name)
+	if !dieNamePattern.MatchString(name) || name == "f" {
+		return fmt.Errorf("%w: invalid die name %q", ErrInvalidDice, name)
+	}
+	if len(faces) == 0 {
+		return fmt.Errorf("%w: die %q has no faces", ErrInvalidDice, name)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	registry.dice[name] = append([]int64{}, faces...)
+	clearSpecs()
+	return nil
+}
+
+// registeredDie - Return the faces of the named die, or nil if it has not been registered.
+func registeredDie(name string) []int64 {
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.dice[name]
+}
+
+// defaultExplodeDepth - Maximum number of explosions followed per die, unless set with WithExplodeDepth.
+const defaultExplodeDepth = 3
+
+// maxExplodeDepth - Greatest explosion depth accepted by WithExplodeDepth; each explosion followed is a level of recursion.
+const maxExplodeDepth = 100
+
+// defaultOptions - Options used when none are provided.
+func defaultOptions() *options {
+	return &options{
+		explodeDepth: defaultExplodeDepth,
+		source:       defaultSource,
+	}
+}
+
+// WithExplodeDepth - Set the maximum number of explosions followed per die when calculating the distribution.
+// The depth ranges from 0 (no explosions followed) to 100; depths outside the range are clamped to it.
+func WithExplodeDepth(depth int64) Option {
+	return func(o *options) {
+		switch {
+		case depth < 0:
+			o.explodeDepth = 0
+		case depth > maxExplodeDepth:
+			o.explodeDepth = maxExplodeDepth
+		default:
+			o.explodeDepth = depth
+		}
+	}
+}
+
+// WithDivideByZero - Set how division by zero is handled when calculating the distribution.
+func WithDivideByZero(policy DivideByZero) Option {
+	return func(o *options) {
+		o.divideByZero = policy
+	}
+}
+
+// WithSource - Set the random source used when rolling; it is guarded for concurrent use.
+func WithSource(src rand.Source) Option {
+	return func(o *options) {
+		o.source = newLockedSource(src)
+	}
+}
+
+// WithSeed - Roll with a random source seeded with seed, so the rolls can be repeated.
+func WithSeed(seed int64) Option {
+	return WithSource(rand.NewSource(seed))
+}
+
 // New - Create a new DiceProb instance.
-func New(s string) (*DiceProb, error) {
+func New(s string, opts ...Option) (*DiceProb, error) {
 	// Create our object.
 	obj := &DiceProb{
 		expression:    s,
 		parsed:        &Expression{},
-		distribution:  &map[int64]int64{},
+		distribution:  &map[int64]*big.Int{},
 		probabilities: &map[int64]float64{},
+		exact:         &map[int64]*big.Rat{},
 		bounds:        &[]int64{},
 		outcomes:      &[]int64{},
-		permutations:  int64(0),
+		permutations:  big.NewInt(0),
+		defined:       big.NewInt(0),
+		truncated:     big.NewInt(0),
+		undefined:     big.NewInt(0),
+		options:       defaultOptions(),
+	}
+
+	// Apply the options.
+	for _, opt := range opts {
+		opt(obj.options)
 	}
 
 	// Parse the expression and put it into the object.
 	var err error
 	obj.parsed, err = diceParser.ParseString("", obj.expression)
 	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyntax, err)
+	}
+
+	// Check the expression can be rolled and calculated.
+	if err := obj.parsed.validate(); err != nil {
 		return nil, err
 	}
 
@@ -30,39 +131,109 @@ func New(s string) (*DiceProb, error) {
 	return obj, nil
 }
 
-// rollIt - Using the selected method, roll n dice of s faces, and return the sum.
-func rollIt(method string, n int64, s int64) int64 {
-	// Seed the randomizer.
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	// Depending on the method...
-	switch method {
-	// Mid rolling method.
-	case "m":
-		// Initialize the array of rolls.
-		ret := []int64{}
-		// Loop three times...
-		for i := int64(1); i <= 3; i++ {
-			// Appending a roll to the array.
-			ret = append(ret, (r.Int63n(s) + 1))
+// rollIt - Roll the dice of a diceSpec with the randomizer, and return the result of each die.
+func rollIt(spec *diceSpec, r *rand.Rand) ([]*DieResult, error) {
+	// rollFace - Roll a single face of the die, rerolling as needed, and record any faces rerolled away.
+	rollFace := func(die *DieResult) int64 {
+		face := spec.face(r.Int63n(spec.sides))
+		for spec.reroll != nil && spec.reroll.matches(face) {
+			die.Rerolled = append(die.Rerolled, face)
+			face = spec.face(r.Int63n(spec.sides))
+			if !spec.rerollAll {
+				break
+			}
 		}
-		// Sort the array numerically.
-		sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
-		// Return the middle value.
-		return ret[1]
-	// "Standard" rolling method.
-	case "d":
-		// Initialize the return value.
-		ret := int64(0)
-		// Loop from 1 to n...
-		for i := int64(1); i <= n; i++ {
-			// Add the value of the roll to the return value.
-			ret = ret + (r.Int63n(s) + 1)
+		return face
+	}
+
+	// Penetrating dice reduce each roll after an explosion by 1.
+	penalty := int64(0)
+	if spec.penetrate {
+		penalty = 1
+	}
+
+	// Initialize the array of dice.
+	ret := []*DieResult{}
+	// finish - Set the die's total, and append it to the array; when counting successes, the die's score replaces its total.
+	finish := func(die *DieResult, total int64) {
+		if spec.success != nil {
+			total = spec.score(total)
+		}
+		die.Total = total
+		ret = append(ret, die)
+	}
+	// Loop from 1 to count...
+	for i := int64(1); i <= spec.count; i++ {
+		// Roll the die.
+		die := &DieResult{Percentile: spec.percentile}
+		roll := rollFace(die)
+		die.Rolls = append(die.Rolls, roll)
+		total := roll
+		// While the die explodes, roll again; compounding adds the roll to the die, otherwise it is a new die in the pool.
+		for spec.explode != nil && spec.explode.matches(roll) {
+			die.Exploded = true
+			if !spec.compound {
+				finish(die, total)
+				die = &DieResult{Percentile: spec.percentile}
+				total = 0
+			}
+			roll = rollFace(die)
+			die.Rolls = append(die.Rolls, roll-penalty)
+			var err error
+			total, err = addInt64(total, roll-penalty)
+			if err != nil {
+				return nil, err
+			}
 		}
-		// Return the summed roll.
-		return ret
-	// Should not reach.
-	default:
-		panic("invalid rollIt method")
+		finish(die, total)
+	}
+	// Return the dice.
+	return ret, nil
+}
+
+// addInt64 - Add two integers, or return ErrOverflow if the sum does not fit in an int64.
+func addInt64(a, b int64) (int64, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrOverflow
+	}
+	return sum, nil
+}
+
+// subInt64 - Subtract two integers, or return ErrOverflow if the difference does not fit in an int64.
+func subInt64(a, b int64) (int64, error) {
+	diff := a - b
+	if (b > 0 && diff > a) || (b < 0 && diff < a) {
+		return 0, ErrOverflow
+	}
+	return diff, nil
+}
+
+// mulInt64 - Multiply two integers, or return ErrOverflow if the product does not fit in an int64.
+func mulInt64(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, ErrOverflow
+	}
+	return product, nil
+}
+
+// boolInt64 - Convert a comparison's result to an integer; 1 for true, 0 for false.
+func boolInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// probability - Probability of an outcome of the given frequency, out of total.
+func probability(frequency, total *big.Int) float64 {
+	if total.Sign() == 0 {
+		return 0
 	}
+	ret, _ := big.NewRat(0, 1).SetFrac(frequency, total).Float64()
+	return ret
 }
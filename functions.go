@@ -0,0 +1,78 @@
+package diceprob
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// function - Built-in function callable from an expression.
+type function struct {
+	minArgs int                                          // Fewest arguments accepted.
+	maxArgs int                                          // Most arguments accepted; -1 for no limit.
+	apply   func(args []int64) (int64, error)            // Calculate the function's value from its arguments.
+	fold    func(i int, value, arg int64) (int64, error) // Combine the value of the arguments before i with argument i; nil when every argument is needed at once.
+}
+
+// foldFunction - Built-in function calculated by folding its arguments in turn, so its distribution is folded pairwise.
+func foldFunction(minArgs, maxArgs int, fold func(i int, value, arg int64) (int64, error)) function {
+	apply := func(args []int64) (int64, error) {
+		ret := args[0]
+		for i, arg := range args[1:] {
+			var err error
+			ret, err = fold(i+1, ret, arg)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return ret, nil
+	}
+	return function{minArgs: minArgs, maxArgs: maxArgs, apply: apply, fold: fold}
+}
+
+// functions - Built-in functions, by lower-case name.
+var functions = map[string]function{
+	"min": foldFunction(1, -1, func(i int, value, arg int64) (int64, error) {
+		return minInt64(value, arg), nil
+	}),
+	"max": foldFunction(1, -1, func(i int, value, arg int64) (int64, error) {
+		return maxInt64(value, arg), nil
+	}),
+	"abs": {minArgs: 1, maxArgs: 1, apply: func(args []int64) (int64, error) {
+		if args[0] == math.MinInt64 {
+			return 0, ErrOverflow
+		}
+		if args[0] < 0 {
+			return -args[0], nil
+		}
+		return args[0], nil
+	}},
+	"clamp": foldFunction(3, 3, func(i int, value, arg int64) (int64, error) {
+		// Raise the value to the lower bound, then lower it to the upper bound.
+		if i == 1 {
+			return maxInt64(value, arg), nil
+		}
+		return minInt64(value, arg), nil
+	}),
+	"floordiv": {minArgs: 2, maxArgs: 2, apply: func(args []int64) (int64, error) {
+		return OpDivFloor.divide(args[0], args[1])
+	}},
+	"ceildiv": {minArgs: 2, maxArgs: 2, apply: func(args []int64) (int64, error) {
+		return OpDivCeil.divide(args[0], args[1])
+	}},
+	"rounddiv": {minArgs: 2, maxArgs: 2, apply: func(args []int64) (int64, error) {
+		return OpDivRound.divide(args[0], args[1])
+	}},
+}
+
+// lookup - Find the built-in function called, and check it accepts the number of arguments given.
+func (c *Call) lookup() (function, error) {
+	f, ok := functions[strings.ToLower(c.Name)]
+	if !ok {
+		return function{}, fmt.Errorf("%w: unknown function %q", ErrInvalidFunction, c.Name)
+	}
+	if len(c.Args) < f.minArgs || (f.maxArgs >= 0 && len(c.Args) > f.maxArgs) {
+		return function{}, fmt.Errorf("%w: %s given %d arguments", ErrInvalidFunction, c.Name, len(c.Args))
+	}
+	return f, nil
+}
@@ -1,6 +1,9 @@
 package diceprob
 
 import (
+	"math"
+	"math/big"
+	"math/rand"
 	"sort"
 	"strconv"
 )
@@ -16,8 +19,27 @@ func (d *DiceProb) ParsedExpression() *Expression {
 }
 
 // Roll - Perform a "roll" of the expression and return the outcome.
-func (d *DiceProb) Roll() int64 {
-	return d.parsed.Roll()
+func (d *DiceProb) Roll() (int64, error) {
+	return d.parsed.RollWith(d.options.source)
+}
+
+// RollMany - Perform n "rolls" of the expression and return how often each outcome was rolled.
+func (d *DiceProb) RollMany(n int) (*map[int64]int64, error) {
+	ret := map[int64]int64{}
+	r := rand.New(d.options.source)
+	for i := 0; i < n; i++ {
+		result, err := d.parsed.roll(r)
+		if err != nil {
+			return nil, err
+		}
+		ret[result.Total]++
+	}
+	return &ret, nil
+}
+
+// RollDetailed - Perform a "roll" of the expression and return every value rolled along with the outcome.
+func (d *DiceProb) RollDetailed() (*ExpressionResult, error) {
+	return d.parsed.RollDetailedWith(d.options.source)
 }
 
 // Min - Minimum outcome value for the expression's distribution.
@@ -49,13 +71,13 @@ func (d *DiceProb) OutcomesString() *[]string {
 	return &ret
 }
 
-// Permutations - Total outcomes for the expression.
-func (d *DiceProb) Permutations() int64 {
+// Permutations - Total outcomes for the expression, including any cut off from the distribution.
+func (d *DiceProb) Permutations() *big.Int {
 	return d.permutations
 }
 
 // Distribution - Distribution of summed outcomes and their frequency.
-func (d *DiceProb) Distribution() *map[int64]int64 {
+func (d *DiceProb) Distribution() *map[int64]*big.Int {
 	return d.distribution
 }
 
@@ -64,9 +86,105 @@ func (d *DiceProb) Probabilities() *map[int64]float64 {
 	return d.probabilities
 }
 
+// ExactProbabilities - Exact probability of each outcome, as a fraction.
+func (d *DiceProb) ExactProbabilities() *map[int64]*big.Rat {
+	return d.exact
+}
+
+// ExactCDF - Exact probability of rolling each outcome or lower, as a fraction.
+func (d *DiceProb) ExactCDF() *map[int64]*big.Rat {
+	ret := map[int64]*big.Rat{}
+	cumulative := big.NewRat(0, 1)
+	for _, outcome := range *d.outcomes {
+		cumulative = big.NewRat(0, 1).Add(cumulative, (*d.exact)[outcome])
+		ret[outcome] = cumulative
+	}
+	return &ret
+}
+
+// ExactSurvival - Exact probability of rolling each outcome or higher, as a fraction.
+func (d *DiceProb) ExactSurvival() *map[int64]*big.Rat {
+	ret := map[int64]*big.Rat{}
+	cumulative := big.NewRat(0, 1)
+	for i := len(*d.outcomes) - 1; i >= 0; i-- {
+		outcome := (*d.outcomes)[i]
+		cumulative = big.NewRat(0, 1).Add(cumulative, (*d.exact)[outcome])
+		ret[outcome] = cumulative
+	}
+	return &ret
+}
+
+// CDF - Probability of rolling each outcome or lower.
+func (d *DiceProb) CDF() *map[int64]float64 {
+	ret := map[int64]float64{}
+	for outcome, cumulative := range *d.ExactCDF() {
+		ret[outcome], _ = cumulative.Float64()
+	}
+	return &ret
+}
+
+// Survival - Probability of rolling each outcome or higher.
+func (d *DiceProb) Survival() *map[int64]float64 {
+	ret := map[int64]float64{}
+	for outcome, cumulative := range *d.ExactSurvival() {
+		ret[outcome], _ = cumulative.Float64()
+	}
+	return &ret
+}
+
+// AtLeast - Probability of rolling n or higher.
+func (d *DiceProb) AtLeast(n int64) float64 {
+	return d.Between(n, math.MaxInt64)
+}
+
+// AtMost - Probability of rolling n or lower.
+func (d *DiceProb) AtMost(n int64) float64 {
+	return d.Between(math.MinInt64, n)
+}
+
+// Between - Probability of rolling between a and b, inclusive.
+func (d *DiceProb) Between(a, b int64) float64 {
+	ret := big.NewRat(0, 1)
+	for outcome, exact := range *d.exact {
+		if outcome >= a && outcome <= b {
+			ret.Add(ret, exact)
+		}
+	}
+	f, _ := ret.Float64()
+	return f
+}
+
+// TruncatedProbability - Probability of the outcomes cut off from the distribution by the explosion depth.
+func (d *DiceProb) TruncatedProbability() float64 {
+	return probability(d.truncated, d.permutations)
+}
+
+// UndefinedProbability - Probability of the outcomes excluded from the distribution by division by zero.
+func (d *DiceProb) UndefinedProbability() float64 {
+	return probability(d.undefined, d.permutations)
+}
+
 // Calculate - Calculate the Distribution and Probabilities for the ParsedExpression.
-func (d *DiceProb) Calculate() {
-	d.distribution = d.parsed.Distribution()
+func (d *DiceProb) Calculate() error {
+	calculated, err := d.parsed.distribution(d.options)
+	if err != nil {
+		return err
+	}
+	return d.calculate(calculated)
+}
+
+// calculate - Fill in the Distribution and Probabilities from the calculated frequencies.
+func (d *DiceProb) calculate(calculated *frequencies) error {
+	d.distribution = &calculated.outcomes
+	d.defined = calculated.defined()
+	d.truncated = calculated.truncated
+	d.undefined = calculated.undefined
+	d.permutations = calculated.total()
+
+	// Every outcome may have been excluded.
+	if len(*d.distribution) == 0 {
+		return ErrDivideByZero
+	}
 
 	keys := make([]int64, 0, len(*d.distribution))
 	for k := range *d.distribution {
@@ -76,13 +194,10 @@ func (d *DiceProb) Calculate() {
 	d.outcomes = &keys
 	d.bounds = &[]int64{keys[0], keys[len(keys)-1]}
 
-	for _, frequency := range *d.distribution {
-		d.permutations = d.permutations + frequency
-	}
-
 	for outcome, frequency := range *d.distribution {
-		(*d.probabilities)[outcome] = float64(frequency) / float64(d.permutations)
+		(*d.exact)[outcome] = big.NewRat(0, 1).SetFrac(frequency, d.permutations)
+		(*d.probabilities)[outcome], _ = (*d.exact)[outcome].Float64()
 	}
 
-	return
+	return nil
 }
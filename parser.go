@@ -1,24 +1,44 @@
 package diceprob
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
 	"github.com/alecthomas/participle/v2"
 	"github.com/alecthomas/participle/v2/lexer"
 )
 
 // Dice expression lexer.
 var diceLexer = lexer.MustSimple([]lexer.SimpleRule{
-	{Name: "DiceRoll", Pattern: `(\d+|[mM][iI])[dD](\d+|[fF])`},
+	{Name: "Whitespace", Pattern: `\s+`},
+	{Name: "Comment", Pattern: `#[^\n]*`},
+	{Name: "Label", Pattern: `\[[^\]]*\]`},
+	{Name: "DiceRoll", Pattern: `(\d+|[mM][iI])?[dD](\d+|%|\{[^{}]*\}|[a-zA-Z]+)([a-zA-Z]*(!!?|![pP])?(>=|<=|[<>=])?\d+|[a-zA-Z!]+)*`},
 	{Name: "Modifier", Pattern: `\d+`},
+	{Name: "Ident", Pattern: `[a-zA-Z_][a-zA-Z0-9_]*`},
+	{Name: ",", Pattern: `,`},
+	{Name: ">=", Pattern: `>=`},
+	{Name: "<=", Pattern: `<=`},
+	{Name: "==", Pattern: `==`},
+	{Name: "!=", Pattern: `!=`},
+	{Name: ">", Pattern: `>`},
+	{Name: "<", Pattern: `<`},
 	{Name: "+", Pattern: `\+`},
 	{Name: "-", Pattern: `-`},
 	{Name: "*", Pattern: `\*`},
+	{Name: "//", Pattern: `//`},
+	{Name: "/^", Pattern: `/\^`},
+	{Name: "/~", Pattern: `/~`},
 	{Name: "/", Pattern: `/`},
 	{Name: "(", Pattern: `\(`},
 	{Name: ")", Pattern: `\)`},
 })
 
 // Parser for our dice expressions.
-var diceParser = participle.MustBuild[Expression](participle.Lexer(diceLexer), participle.UseLookahead(2))
+var diceParser = participle.MustBuild[Expression](participle.Lexer(diceLexer), participle.Elide("Whitespace", "Comment"), participle.UseLookahead(2))
 
 // Operator type
 type Operator int
@@ -29,10 +49,22 @@ const (
 	OpDiv
 	OpAdd
 	OpSub
+	OpDivFloor
+	OpDivCeil
+	OpDivRound
+	OpGt
+	OpGe
+	OpLt
+	OpLe
+	OpEq
+	OpNe
 )
 
 // operatorMap - Map parsed operators to constants.
-var operatorMap = map[string]Operator{"+": OpAdd, "-": OpSub, "*": OpMul, "/": OpDiv}
+var operatorMap = map[string]Operator{
+	"+": OpAdd, "-": OpSub, "*": OpMul, "/": OpDiv, "//": OpDivFloor, "/^": OpDivCeil, "/~": OpDivRound,
+	">": OpGt, ">=": OpGe, "<": OpLt, "<=": OpLe, "==": OpEq, "!=": OpNe,
+}
 
 // Capture - Capture the costants while parsing.
 func (o *Operator) Capture(s []string) error {
@@ -43,8 +75,29 @@ func (o *Operator) Capture(s []string) error {
 // DiceRoll - String representing a dice roll atomic expression.
 type DiceRoll string
 
+// Label - Text labelling an Atom, such as the damage type in "2d6 [fire]".
+type Label string
+
+// Capture - Capture the label's text, without its brackets, while parsing.
+func (l *Label) Capture(s []string) error {
+	*l = Label(strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(s[0], "["), "]")))
+	return nil
+}
+
 // Expression - Top level parsing unit.
 type Expression struct {
+	Sum
+	Comparison *Comparison `parser:"@@?"`
+}
+
+// Comparison - Expression comparison Operator and the Sum it compares against.
+type Comparison struct {
+	Operator Operator `parser:"@('>' | '>=' | '<' | '<=' | '==' | '!=')"`
+	Sum
+}
+
+// Sum - Terms added and subtracted; either side of an Expression's Comparison.
+type Sum struct {
 	Left  *Term     `parser:"@@"`
 	Right []*OpTerm `parser:"@@*"`
 }
@@ -63,13 +116,358 @@ type Term struct {
 
 // OpAtom - Expression Operator and Atom.
 type OpAtom struct {
-	Operator Operator `parser:"@('*' | '/')"`
+	Operator Operator `parser:"@('*' | '/' | '//' | '/^' | '/~')"`
 	Atom     *Atom    `parser:"@@"`
 }
 
-// Atom - Smallest unit of an expression.
+// Atom - Smallest unit of an expression, with an optional label.
 type Atom struct {
-	Modifier      *int64      `parser:"@Modifier"`
+	Modifier      *int64      `parser:"( @Modifier"`
 	RollExpr      *DiceRoll   `parser:"| @DiceRoll"`
 	SubExpression *Expression `parser:"| '(' @@ ')'"`
+	Unary         *Unary      `parser:"| @@"`
+	Call          *Call       `parser:"| @@ )"`
+	Label         *Label      `parser:"@Label?"`
+}
+
+// Call - Call of a built-in function, such as max(1d20, 1d20).
+type Call struct {
+	Name string        `parser:"@Ident"`
+	Args []*Expression `parser:"'(' @@ ( ',' @@ )* ')'"`
+}
+
+// Unary - Unary Operator and the Atom it applies to; negation or plus.
+type Unary struct {
+	Operator Operator `parser:"@('-' | '+')"`
+	Atom     *Atom    `parser:"@@"`
+}
+
+// diceRollPattern - Regular expression splitting a (lower-cased) DiceRoll into its parts.
+var diceRollPattern = regexp.MustCompile(`^(?P<count>\d+|mi)?d(?P<sides>\d+|%|\{[^{}]*\}|[a-z]+?)(?:(?P<reroll>rr?)(?P<rerollCond>(?:>=|<=|[<>=])?\d+))?(?P<explode>!(?P<explodeKind>!|p)?(?P<explodeCond>(?:>=|<=|[<>=])\d+)?)?(?:(?P<select>[kd][hl]|[om])(?P<selectCount>\d+))?(?:(?P<success>(?:>=|<=|[<>=])\d+)(?:f(?P<failure>(?:>=|<=|[<>=])?\d+))?)?$`)
+
+// diceSpec - The parts of a DiceRoll, parsed and ready for rolling or calculating.
+type diceSpec struct {
+	count      int64      // Number of dice rolled.
+	sides      int64      // Number of faces on a single die.
+	faces      []int64    // Face values of a single die; nil for standard dice, whose faces are 1 to sides.
+	fudge      bool       // Fudge/FATE dice; faces are -1, 0 and 1.
+	custom     bool       // Custom dice; faces given in braces, or by a named die.
+	percentile bool       // Percentile dice; d% or d100, read as a tens die and a ones die.
+	order      int64      // Order statistic; the value of the order-th lowest die, or 0 when the dice are summed.
+	keep       int64      // Number of dice kept after rolling.
+	keepHigh   bool       // Keep the highest dice when true, the lowest when false.
+	drop       bool       // The dice not kept are dropped; count-keep dice are dropped from the pool, however large.
+	reroll     *condition // Faces on which a die is rerolled; nil when the dice are not rerolled.
+	rerollAll  bool       // Reroll until the face no longer matches, rather than only once.
+	explode    *condition // Faces on which a die explodes; nil when the dice do not explode.
+	compound   bool       // Compounding explosions; the rolls of each die are summed into a single die.
+	penetrate  bool       // Penetrating explosions; each roll after an explosion is reduced by 1.
+	success    *condition // Faces counted as a success; nil when the dice are summed instead.
+	failure    *condition // Faces counted against the successes; nil when there are none.
+}
+
+// face - Value of the ith face of a single die, counting from 0.
+func (spec *diceSpec) face(i int64) int64 {
+	if spec.faces == nil {
+		return i + 1
+	}
+	return spec.faces[i]
+}
+
+// allFaces - Face values of a single die, listing the faces of standard dice.
+func (spec *diceSpec) allFaces() []int64 {
+	if spec.faces != nil {
+		return spec.faces
+	}
+	ret := make([]int64, 0, spec.sides)
+	for face := int64(1); face <= spec.sides; face++ {
+		ret = append(ret, face)
+	}
+	return ret
+}
+
+// score - Score a face when counting successes; 1 for a success, -1 for a failure, and 0 otherwise.
+func (spec *diceSpec) score(face int64) int64 {
+	switch {
+	case spec.success.matches(face):
+		return 1
+	case spec.failure != nil && spec.failure.matches(face):
+		return -1
+	default:
+		return 0
+	}
+}
+
+// conditionPattern - Regular expression splitting a condition into its comparison and value.
+var conditionPattern = regexp.MustCompile(`^(>=|<=|[<>=])?(\d+)$`)
+
+// condition - Comparison of a die's face against a value.
+type condition struct {
+	compare string // Comparison; one of "=", "<", "<=", ">" or ">=".
+	value   int64  // Value compared against.
+}
+
+// parseCondition - Parse a condition such as ">=9" or "=1"; a bare value such as "1" compares for equality.
+func parseCondition(s string) (*condition, error) {
+	m := conditionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("%w: invalid condition %q", ErrInvalidDice, s)
+	}
+	value, err := strconv.ParseInt(m[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid condition %q: %v", ErrInvalidDice, s, err)
+	}
+	compare := m[1]
+	if compare == "" {
+		compare = "="
+	}
+	return &condition{compare: compare, value: value}, nil
+}
+
+// matches - Determine whether the face satisfies the condition.
+func (c *condition) matches(face int64) bool {
+	switch c.compare {
+	case "<":
+		return face < c.value
+	case "<=":
+		return face <= c.value
+	case ">":
+		return face > c.value
+	case ">=":
+		return face >= c.value
+	default:
+		return face == c.value
+	}
+}
+
+// matchesAll - Determine whether every one of the faces satisfies the condition.
+func (c *condition) matchesAll(faces []int64) bool {
+	for _, face := range faces {
+		if !c.matches(face) {
+			return false
+		}
+	}
+	return true
+}
+
+// specs - Parsed diceSpecs, by DiceRoll; cleared when a die is registered, and when it grows past maxSpecs.
+var specs = struct {
+	sync.RWMutex
+	parsed map[DiceRoll]*diceSpec
+}{parsed: map[DiceRoll]*diceSpec{}}
+
+// maxSpecs - Most diceSpecs kept in specs.
+const maxSpecs = 1024
+
+// clearSpecs - Forget the parsed diceSpecs, so they are parsed again.
+func clearSpecs() {
+	specs.Lock()
+	defer specs.Unlock()
+	specs.parsed = map[DiceRoll]*diceSpec{}
+}
+
+// parse - Parse the DiceRoll into a diceSpec, or return the diceSpec already parsed; it must not be modified.
+func (s *DiceRoll) parse() (*diceSpec, error) {
+	specs.RLock()
+	spec, ok := specs.parsed[*s]
+	specs.RUnlock()
+	if ok {
+		return spec, nil
+	}
+
+	spec, err := s.parseSpec()
+	if err != nil {
+		return nil, err
+	}
+
+	specs.Lock()
+	defer specs.Unlock()
+	if len(specs.parsed) >= maxSpecs {
+		specs.parsed = map[DiceRoll]*diceSpec{}
+	}
+	specs.parsed[*s] = spec
+	return spec, nil
+}
+
+// parseSpec - Parse the DiceRoll into a new diceSpec.
+func (s *DiceRoll) parseSpec() (*diceSpec, error) {
+	// Convert s to a string.
+	sActual := strings.ToLower(string(*s))
+
+	// Parse the roll syntax.
+	m := diceRollPattern.FindStringSubmatch(sActual)
+	if m == nil {
+		return nil, fmt.Errorf("%w %q", ErrInvalidDice, string(*s))
+	}
+	left := m[diceRollPattern.SubexpIndex("count")]
+	right := m[diceRollPattern.SubexpIndex("sides")]
+	selector := m[diceRollPattern.SubexpIndex("select")]
+
+	spec := &diceSpec{}
+
+	// Determine the faces of a single die.
+	switch {
+	case right == "%":
+		// Percentile dice have faces of 1 to 100.
+		spec.percentile = true
+		spec.sides = 100
+	case right == "f":
+		// Fudge/FATE dice have faces of -1, 0 and 1.
+		spec.fudge = true
+		spec.faces = []int64{-1, 0, 1}
+		spec.sides = 3
+	case strings.HasPrefix(right, "{"):
+		// Custom dice list their faces in braces.
+		spec.custom = true
+		for _, face := range strings.Split(strings.Trim(right, "{}"), ",") {
+			value, err := strconv.ParseInt(strings.TrimSpace(face), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w %q: invalid face %q", ErrInvalidDice, string(*s), face)
+			}
+			spec.faces = append(spec.faces, value)
+		}
+		spec.sides = int64(len(spec.faces))
+	case right[0] >= 'a' && right[0] <= 'z':
+		// Named dice are looked up in the registry.
+		spec.custom = true
+		spec.faces = registeredDie(right)
+		if spec.faces == nil {
+			return nil, fmt.Errorf("%w %q: unknown die %q", ErrInvalidDice, string(*s), right)
+		}
+		spec.sides = int64(len(spec.faces))
+	default:
+		sides, err := strconv.ParseInt(right, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidDice, string(*s), err)
+		}
+		spec.sides = sides
+		spec.percentile = sides == 100
+	}
+
+	// Determine the number of dice; a single die when the count is left out.
+	switch left {
+	case "":
+		spec.count = 1
+	case "mi":
+		// "Middle" rolls are the second lowest of three dice.
+		spec.order = 2
+		spec.count = 3
+	default:
+		count, err := strconv.ParseInt(left, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidDice, string(*s), err)
+		}
+		spec.count = count
+	}
+
+	// There must be at least one die, with at least one face.
+	if spec.count < 1 {
+		return nil, fmt.Errorf("%w %q: no dice to roll", ErrInvalidDice, string(*s))
+	}
+	if spec.sides < 1 {
+		return nil, fmt.Errorf("%w %q: dice have no faces", ErrInvalidDice, string(*s))
+	}
+
+	// By default every die is kept.
+	spec.keep = spec.count
+	spec.keepHigh = true
+
+	// Rerolls and explosions test the faces of standard dice, so list them.
+	if m[diceRollPattern.SubexpIndex("reroll")] != "" || m[diceRollPattern.SubexpIndex("explode")] != "" {
+		spec.faces = spec.allFaces()
+	}
+
+	// Reroll the faces given, once or until they no longer match.
+	if reroll := m[diceRollPattern.SubexpIndex("reroll")]; reroll != "" {
+		var err error
+		spec.reroll, err = parseCondition(m[diceRollPattern.SubexpIndex("rerollCond")])
+		if err != nil {
+			return nil, err
+		}
+		spec.rerollAll = reroll == "rr"
+		if spec.rerollAll && spec.reroll.matchesAll(spec.faces) {
+			return nil, fmt.Errorf("%w %q: dice would reroll forever", ErrInvalidDice, string(*s))
+		}
+	}
+
+	// Explode on the highest face, or on the faces given.
+	if m[diceRollPattern.SubexpIndex("explode")] != "" {
+		if cond := m[diceRollPattern.SubexpIndex("explodeCond")]; cond != "" {
+			var err error
+			spec.explode, err = parseCondition(cond)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			highest := spec.faces[0]
+			for _, face := range spec.faces {
+				if face > highest {
+					highest = face
+				}
+			}
+			spec.explode = &condition{compare: "=", value: highest}
+		}
+		switch m[diceRollPattern.SubexpIndex("explodeKind")] {
+		case "!":
+			spec.compound = true
+		case "p":
+			spec.penetrate = true
+		}
+		// Faces removed by rerolling until they no longer match can never explode.
+		possible := []int64{}
+		for _, face := range spec.faces {
+			if !spec.rerollAll || !spec.reroll.matches(face) {
+				possible = append(possible, face)
+			}
+		}
+		if spec.explode.matchesAll(possible) {
+			return nil, fmt.Errorf("%w %q: dice would explode forever", ErrInvalidDice, string(*s))
+		}
+	}
+
+	// Keep or drop the highest or lowest dice, or take the value of the nth lowest.
+	if selector != "" {
+		if spec.order > 0 {
+			return nil, fmt.Errorf("%w %q: cannot select dice of a middle roll", ErrInvalidDice, string(*s))
+		}
+		n, err := strconv.ParseInt(m[diceRollPattern.SubexpIndex("selectCount")], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidDice, string(*s), err)
+		}
+		if (selector == "o" || selector == "m") && (n < 1 || n > spec.count) {
+			return nil, fmt.Errorf("%w %q: cannot take die %d of %d dice", ErrInvalidDice, string(*s), n, spec.count)
+		}
+		if n > spec.count {
+			return nil, fmt.Errorf("%w %q: cannot keep or drop %d of %d dice", ErrInvalidDice, string(*s), n, spec.count)
+		}
+		switch selector {
+		case "kh":
+			spec.keep = n
+		case "kl":
+			spec.keep, spec.keepHigh = n, false
+		case "dh":
+			spec.keep, spec.keepHigh, spec.drop = spec.count-n, false, true
+		case "dl":
+			spec.keep, spec.drop = spec.count-n, true
+		case "o", "m":
+			spec.order = n
+		}
+	}
+
+	// Count the successes (less any failures), rather than summing the dice.
+	if success := m[diceRollPattern.SubexpIndex("success")]; success != "" {
+		var err error
+		spec.success, err = parseCondition(success)
+		if err != nil {
+			return nil, err
+		}
+		if failure := m[diceRollPattern.SubexpIndex("failure")]; failure != "" {
+			spec.failure, err = parseCondition(failure)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return spec, nil
 }
@@ -0,0 +1,67 @@
+package diceprob
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// defaultSource - Random source used when none is provided; seeded once, and safe for concurrent use.
+var defaultSource rand.Source = newLockedSource(rand.NewSource(time.Now().UnixNano()))
+
+// lockedSource - Random source guarded by a mutex, so it is safe for concurrent use.
+type lockedSource struct {
+	sync.Mutex
+	src rand.Source
+}
+
+// newLockedSource - Guard a random source with a mutex.
+func newLockedSource(src rand.Source) *lockedSource {
+	return &lockedSource{src: src}
+}
+
+// Int63 - Return a non-negative random 63-bit integer.
+func (s *lockedSource) Int63() int64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.src.Int63()
+}
+
+// Uint64 - Return a random 64-bit integer.
+func (s *lockedSource) Uint64() uint64 {
+	s.Lock()
+	defer s.Unlock()
+	if src64, ok := s.src.(rand.Source64); ok {
+		return src64.Uint64()
+	}
+	return uint64(s.src.Int63())>>31 | uint64(s.src.Int63())<<32
+}
+
+// Seed - Reseed the random source.
+func (s *lockedSource) Seed(seed int64) {
+	s.Lock()
+	defer s.Unlock()
+	s.src.Seed(seed)
+}
+
+// CryptoSource - Random source reading from crypto/rand, for rolls that must not be predicted.
+type CryptoSource struct{}
+
+// Int63 - Return a non-negative random 63-bit integer.
+func (CryptoSource) Int63() int64 {
+	return int64(CryptoSource{}.Uint64() >> 1)
+}
+
+// Uint64 - Return a random 64-bit integer; panics if the operating system's random source fails.
+func (CryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic("diceprob: reading crypto/rand: " + err.Error())
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// Seed - Do nothing; the source cannot be seeded.
+func (CryptoSource) Seed(int64) {}
@@ -0,0 +1,219 @@
+package diceprob
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExpressionResult - Rolled Expression; mirrors the Expression, recording each value rolled.
+type ExpressionResult struct {
+	SumResult                    // Rolled Sum, compared by the Comparison when there is one.
+	Comparison *ComparisonResult // Rolled Comparison; nil when the Expression has none.
+	Total      int64             // Value of the Expression; the result of the Comparison when there is one.
+}
+
+// SumResult - Rolled Sum; mirrors the Sum.
+type SumResult struct {
+	Left  *TermResult     // Rolled left-hand Term.
+	Right []*OpTermResult // Rolled Operators and Terms.
+	Value int64           // Value of the Sum; named apart from the Total of the ExpressionResult it is embedded in.
+}
+
+// OpTermResult - Expression Operator and rolled Term.
+type OpTermResult struct {
+	Operator Operator    // Operator applied to the Term.
+	Term     *TermResult // Rolled Term.
+}
+
+// ComparisonResult - Comparison Operator and the rolled Sum it compares against.
+type ComparisonResult struct {
+	Operator  Operator // Comparison Operator.
+	SumResult          // Rolled Sum compared against.
+}
+
+// TermResult - Rolled Term; mirrors the Term.
+type TermResult struct {
+	Left  *AtomResult     // Rolled left-hand Atom.
+	Right []*OpAtomResult // Rolled Operators and Atoms.
+	Total int64           // Value of the Term.
+}
+
+// OpAtomResult - Term Operator and rolled Atom.
+type OpAtomResult struct {
+	Operator Operator    // Operator applied to the Atom.
+	Atom     *AtomResult // Rolled Atom.
+}
+
+// AtomResult - Rolled Atom; one of a Modifier, dice, a sub-expression, a unary Operator or a function call.
+type AtomResult struct {
+	Modifier      *int64            // Fixed number.
+	Dice          *DiceResult       // Rolled dice.
+	SubExpression *ExpressionResult // Rolled sub-expression.
+	Unary         *UnaryResult      // Rolled unary Operator and Atom.
+	Call          *CallResult       // Rolled function call.
+	Label         *Label            // Label of the Atom; nil when it has none.
+	Total         int64             // Value of the Atom.
+}
+
+// UnaryResult - Unary Operator and rolled Atom.
+type UnaryResult struct {
+	Operator Operator    // Unary Operator applied to the Atom.
+	Atom     *AtomResult // Rolled Atom.
+	Total    int64       // Value of the Atom, after the Operator.
+}
+
+// CallResult - Function call, with its rolled arguments.
+type CallResult struct {
+	Name  string              // Name of the function called.
+	Args  []*ExpressionResult // Rolled arguments.
+	Total int64               // Value returned by the function.
+}
+
+// DiceResult - Rolled DiceRoll, with the result of each die.
+type DiceResult struct {
+	Roll  DiceRoll     // Dice roll notation.
+	Dice  []*DieResult // Result of each die, in the order rolled.
+	Total int64        // Value of the kept dice.
+}
+
+// DieResult - Result of a single die.
+type DieResult struct {
+	Rolls      []int64 // Each roll of the die; more than one when a compounding die exploded, reduced by 1 after an explosion when penetrating.
+	Rerolled   []int64 // Faces rolled and then rerolled away.
+	Exploded   bool    // The die exploded; unless compounding, the next die in the pool was rolled for the explosion.
+	Dropped    bool    // The die was dropped, and does not count towards the total.
+	Percentile bool    // The die is a percentile die, read as a tens die (00 to 90) and a ones die (0 to 9).
+	Total      int64   // Value of the die; its rolls summed, or its score when counting successes.
+}
+
+// String - Output the rolled Expression as a transcript, such as "3d6 [4,2,6] + 2 = 12 + 2 = 14".
+func (e *ExpressionResult) String() string {
+	out := []string{e.detail()}
+	if subtotals := e.subtotals(); subtotals != out[0] {
+		out = append(out, subtotals)
+	}
+	if total := fmt.Sprintf("%d", e.Total); total != out[len(out)-1] {
+		out = append(out, total)
+	}
+	return strings.Join(out, " = ")
+}
+
+// detail - Output the rolled Expression with each die's rolls; part of the recursive transcript functions.
+func (e *ExpressionResult) detail() string {
+	out := []string{e.SumResult.detail()}
+	if c := e.Comparison; c != nil {
+		out = append(out, c.Operator.string(), c.SumResult.detail())
+	}
+	return strings.Join(out, " ")
+}
+
+// subtotals - Output the rolled Expression with the value of each Atom; part of the recursive transcript functions.
+func (e *ExpressionResult) subtotals() string {
+	out := []string{e.SumResult.subtotals()}
+	if c := e.Comparison; c != nil {
+		out = append(out, c.Operator.string(), c.SumResult.subtotals())
+	}
+	return strings.Join(out, " ")
+}
+
+// detail - Output the rolled Sum with each die's rolls; part of the recursive transcript functions.
+func (s *SumResult) detail() string {
+	out := []string{s.Left.detail()}
+	for _, r := range s.Right {
+		out = append(out, r.Operator.string(), r.Term.detail())
+	}
+	return strings.Join(out, " ")
+}
+
+// subtotals - Output the rolled Sum with the value of each Atom; part of the recursive transcript functions.
+func (s *SumResult) subtotals() string {
+	out := []string{s.Left.subtotals()}
+	for _, r := range s.Right {
+		out = append(out, r.Operator.string(), r.Term.subtotals())
+	}
+	return strings.Join(out, " ")
+}
+
+// detail - Output the rolled Term with each die's rolls; part of the recursive transcript functions.
+func (t *TermResult) detail() string {
+	out := []string{t.Left.detail()}
+	for _, r := range t.Right {
+		out = append(out, r.Operator.string(), r.Atom.detail())
+	}
+	return strings.Join(out, " ")
+}
+
+// subtotals - Output the rolled Term with the value of each Atom; part of the recursive transcript functions.
+func (t *TermResult) subtotals() string {
+	out := []string{t.Left.subtotals()}
+	for _, r := range t.Right {
+		out = append(out, r.Operator.string(), r.Atom.subtotals())
+	}
+	return strings.Join(out, " ")
+}
+
+// detail - Output the rolled Atom with each die's rolls; part of the recursive transcript functions.
+func (a *AtomResult) detail() string {
+	ret := ""
+	switch {
+	case a.Modifier != nil:
+		ret = fmt.Sprintf("%d", *a.Modifier)
+	case a.Dice != nil:
+		ret = a.Dice.detail()
+	case a.Unary != nil:
+		ret = a.Unary.Operator.string() + a.Unary.Atom.detail()
+	case a.Call != nil:
+		args := []string{}
+		for _, arg := range a.Call.Args {
+			args = append(args, arg.detail())
+		}
+		ret = a.Call.Name + "(" + strings.Join(args, ", ") + ")"
+	default:
+		ret = "(" + a.SubExpression.detail() + ")"
+	}
+	if a.Label != nil {
+		ret += " " + a.Label.string()
+	}
+	return ret
+}
+
+// subtotals - Output the value of the rolled Atom; part of the recursive transcript functions.
+func (a *AtomResult) subtotals() string {
+	return fmt.Sprintf("%d", a.Total)
+}
+
+// detail - Output the rolled dice, such as "4d6kh3 [6,3,1d,5]"; the deepest of the recursive transcript functions.
+func (s *DiceResult) detail() string {
+	out := []string{}
+	for _, die := range s.Dice {
+		out = append(out, die.String())
+	}
+	return fmt.Sprintf("%s [%s]", s.Roll.string(), strings.Join(out, ","))
+}
+
+// String - Output the die's rolls; a rerolled face is followed by "r", the rolls of a compounding die are joined by "!", a die
+// which added another to the pool by exploding ends with "!", and a dropped die ends with "d".
+// Percentile rolls are followed by their tens and ones dice, as in "47(40+7)".
+func (d *DieResult) String() string {
+	ret := ""
+	for _, face := range d.Rerolled {
+		ret += fmt.Sprintf("%dr", face)
+	}
+	rolls := []string{}
+	for _, roll := range d.Rolls {
+		if d.Percentile && roll >= 1 && roll <= 100 {
+			// Show the tens and ones dice; 00 and 0 together read as 100.
+			rolls = append(rolls, fmt.Sprintf("%d(%02d+%d)", roll, roll/10%10*10, roll%10))
+			continue
+		}
+		rolls = append(rolls, fmt.Sprintf("%d", roll))
+	}
+	ret += strings.Join(rolls, "!")
+	if d.Exploded && len(d.Rolls) == 1 {
+		ret += "!"
+	}
+	if d.Dropped {
+		ret += "d"
+	}
+	return ret
+}
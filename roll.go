@@ -1,85 +1,324 @@
 package diceprob
 
 import (
-	"strconv"
-	"strings"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
 )
 
 // Roll - Roll a random value for the Expression; top-level of the recursive roll functions.
-func (e *Expression) Roll() int64 {
-	left := e.Left.Roll()
-	for _, right := range e.Right {
-		left = right.Operator.Roll(left, right.Term.Roll())
+func (e *Expression) Roll() (int64, error) {
+	return e.RollWith(defaultSource)
+}
+
+// RollWith - Roll a random value for the Expression, drawing from the random source src.
+func (e *Expression) RollWith(src rand.Source) (int64, error) {
+	result, err := e.RollDetailedWith(src)
+	if err != nil {
+		return 0, err
+	}
+	return result.Total, nil
+}
+
+// RollDetailed - Roll the Expression, and return every value rolled along with the total.
+func (e *Expression) RollDetailed() (*ExpressionResult, error) {
+	return e.RollDetailedWith(defaultSource)
+}
+
+// RollDetailedWith - Roll the Expression drawing from the random source src, and return every value rolled along with the total.
+func (e *Expression) RollDetailedWith(src rand.Source) (*ExpressionResult, error) {
+	return e.roll(rand.New(src))
+}
+
+// roll - Roll the Expression with the randomizer; part of the recursive roll functions.
+func (e *Expression) roll(r *rand.Rand) (*ExpressionResult, error) {
+	sum, err := e.Sum.roll(r)
+	if err != nil {
+		return nil, err
+	}
+	ret := &ExpressionResult{SumResult: *sum, Total: sum.Value}
+	if e.Comparison != nil {
+		compared, err := e.Comparison.Sum.roll(r)
+		if err != nil {
+			return nil, err
+		}
+		ret.Comparison = &ComparisonResult{Operator: e.Comparison.Operator, SumResult: *compared}
+		ret.Total, err = e.Comparison.Operator.Roll(ret.Total, compared.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// roll - Roll the Sum with the randomizer; part of the recursive roll functions.
+func (s *Sum) roll(r *rand.Rand) (*SumResult, error) {
+	left, err := s.Left.roll(r)
+	if err != nil {
+		return nil, err
+	}
+	ret := &SumResult{Left: left, Value: left.Total}
+	for _, right := range s.Right {
+		term, err := right.Term.roll(r)
+		if err != nil {
+			return nil, err
+		}
+		ret.Right = append(ret.Right, &OpTermResult{Operator: right.Operator, Term: term})
+		ret.Value, err = right.Operator.Roll(ret.Value, term.Total)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return left
+	return ret, nil
 }
 
 // Roll - Roll a random values around the Operator; part of the recursive roll functions.
-func (o Operator) Roll(left, right int64) int64 {
+func (o Operator) Roll(left, right int64) (int64, error) {
 	switch o {
 	case OpMul:
-		return left * right
-	case OpDiv:
-		return left / right
+		return mulInt64(left, right)
+	case OpDiv, OpDivFloor, OpDivCeil, OpDivRound:
+		return o.divide(left, right)
 	case OpAdd:
-		return left + right
+		return addInt64(left, right)
 	case OpSub:
-		return left - right
+		return subInt64(left, right)
+	case OpGt:
+		return boolInt64(left > right), nil
+	case OpGe:
+		return boolInt64(left >= right), nil
+	case OpLt:
+		return boolInt64(left < right), nil
+	case OpLe:
+		return boolInt64(left <= right), nil
+	case OpEq:
+		return boolInt64(left == right), nil
+	case OpNe:
+		return boolInt64(left != right), nil
+	}
+	return 0, fmt.Errorf("%w %d", ErrUnsupportedOperator, o)
+}
+
+// divide - Divide left by right, rounding as the Operator requires.
+func (o Operator) divide(left, right int64) (int64, error) {
+	if right == 0 {
+		return 0, ErrDivideByZero
+	}
+	if left == math.MinInt64 && right == -1 {
+		return 0, ErrOverflow
+	}
+
+	// Go's division truncates toward zero; adjust the quotient when there is a remainder.
+	quotient := left / right
+	remainder := left % right
+	if remainder == 0 {
+		return quotient, nil
+	}
+	negative := (left < 0) != (right < 0)
+	switch o {
+	case OpDivFloor:
+		// Round toward negative infinity.
+		if negative {
+			quotient--
+		}
+	case OpDivCeil:
+		// Round toward positive infinity.
+		if !negative {
+			quotient++
+		}
+	case OpDivRound:
+		// Round to the nearest integer, with halves toward positive infinity; compare magnitudes unsigned.
+		r, b := uint64(remainder), uint64(right)
+		if remainder < 0 {
+			r = uint64(-remainder)
+		}
+		if right < 0 {
+			b = uint64(-right)
+		}
+		if negative && r > b-r {
+			quotient--
+		}
+		if !negative && r >= b-r {
+			quotient++
+		}
+	}
+	return quotient, nil
+}
+
+// Roll - Roll a random value for the Term.
+func (t *Term) Roll() (int64, error) {
+	result, err := t.roll(rand.New(defaultSource))
+	if err != nil {
+		return 0, err
 	}
-	panic("unsupported operator") // TODO - We can do better here.
+	return result.Total, nil
 }
 
-// Roll - Roll a random value for the Term; part of the recursive roll functions.
-func (t *Term) Roll() int64 {
-	left := t.Left.Roll()
+// roll - Roll the Term with the randomizer; part of the recursive roll functions.
+func (t *Term) roll(r *rand.Rand) (*TermResult, error) {
+	left, err := t.Left.roll(r)
+	if err != nil {
+		return nil, err
+	}
+	ret := &TermResult{Left: left, Total: left.Total}
 	for _, right := range t.Right {
-		left = right.Operator.Roll(left, right.Atom.Roll())
+		atom, err := right.Atom.roll(r)
+		if err != nil {
+			return nil, err
+		}
+		ret.Right = append(ret.Right, &OpAtomResult{Operator: right.Operator, Atom: atom})
+		ret.Total, err = right.Operator.Roll(ret.Total, atom.Total)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return left
+	return ret, nil
 }
 
-// Roll - Roll a random value for the Atom; part of the recursive roll functions.
-func (a *Atom) Roll() int64 {
+// Roll - Roll a random value for the Atom.
+func (a *Atom) Roll() (int64, error) {
+	result, err := a.roll(rand.New(defaultSource))
+	if err != nil {
+		return 0, err
+	}
+	return result.Total, nil
+}
+
+// roll - Roll the Atom with the randomizer; part of the recursive roll functions.
+func (a *Atom) roll(r *rand.Rand) (*AtomResult, error) {
+	ret := &AtomResult{Label: a.Label}
 	switch {
 	case a.Modifier != nil:
-		return *a.Modifier
+		ret.Modifier = a.Modifier
+		ret.Total = *a.Modifier
 	case a.RollExpr != nil:
-		return a.RollExpr.Roll()
+		dice, err := a.RollExpr.roll(r)
+		if err != nil {
+			return nil, err
+		}
+		ret.Dice = dice
+		ret.Total = dice.Total
+	case a.Unary != nil:
+		unary, err := a.Unary.roll(r)
+		if err != nil {
+			return nil, err
+		}
+		ret.Unary = unary
+		ret.Total = unary.Total
+	case a.Call != nil:
+		call, err := a.Call.roll(r)
+		if err != nil {
+			return nil, err
+		}
+		ret.Call = call
+		ret.Total = call.Total
 	default:
-		return a.SubExpression.Roll()
+		sub, err := a.SubExpression.roll(r)
+		if err != nil {
+			return nil, err
+		}
+		ret.SubExpression = sub
+		ret.Total = sub.Total
 	}
+	return ret, nil
 }
 
-// Roll - Roll a random value for the DiceRoll; deepest of the recursive roll functions.
-func (s *DiceRoll) Roll() int64 {
-	// Convert s to a string.
-	sActual := strings.ToLower(string(*s))
+// roll - Roll the Unary with the randomizer; part of the recursive roll functions.
+func (u *Unary) roll(r *rand.Rand) (*UnaryResult, error) {
+	atom, err := u.Atom.roll(r)
+	if err != nil {
+		return nil, err
+	}
+	// Negation subtracts the Atom from zero; plus adds it.
+	total, err := u.Operator.Roll(0, atom.Total)
+	if err != nil {
+		return nil, err
+	}
+	return &UnaryResult{Operator: u.Operator, Atom: atom, Total: total}, nil
+}
 
-	// Find the D in the roll.
-	dToken := strings.Index(sActual, "d")
-	if dToken == -1 {
-		panic("invalid dice roll atomic expression")
+// roll - Roll the arguments of the Call with the randomizer, and apply the function; part of the recursive roll functions.
+func (c *Call) roll(r *rand.Rand) (*CallResult, error) {
+	f, err := c.lookup()
+	if err != nil {
+		return nil, err
 	}
+	ret := &CallResult{Name: c.Name}
+	values := []int64{}
+	for _, arg := range c.Args {
+		result, err := arg.roll(r)
+		if err != nil {
+			return nil, err
+		}
+		ret.Args = append(ret.Args, result)
+		values = append(values, result.Total)
+	}
+	ret.Total, err = f.apply(values)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
 
-	// Grab the digits to the right of the D.
-	right, err := strconv.ParseInt(sActual[dToken+1:], 10, 64)
+// Roll - Roll a random value for the DiceRoll.
+func (s *DiceRoll) Roll() (int64, error) {
+	result, err := s.roll(rand.New(defaultSource))
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
+	return result.Total, nil
+}
 
-	// If the dice roll is a "middle" roll of 3 dice.
-	if sActual[0:3] == "mid" {
-		// Return a middle rolled value.
-		return rollIt("m", 3, right)
+// roll - Roll the DiceRoll with the randomizer; deepest of the recursive roll functions.
+func (s *DiceRoll) roll(r *rand.Rand) (*DiceResult, error) {
+	// Parse the dice roll.
+	spec, err := s.parse()
+	if err != nil {
+		return nil, err
 	}
-	// Not a "middle" roll, therefore a standard roll.
 
-	// Grab the number of dice from the left of the D.
-	left, err := strconv.ParseInt(sActual[0:dToken], 10, 64)
+	// Roll the dice.
+	dice, err := rollIt(spec, r)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	// Order the dice by preference, so those kept come first; the dice themselves stay in the order rolled.
+	order := make([]*DieResult, len(dice))
+	copy(order, dice)
+	kept := order
+	switch {
+	case spec.order > 0:
+		// Sort the rolls numerically, and keep the order-th lowest.
+		sort.SliceStable(order, func(i, j int) bool { return order[i].Total < order[j].Total })
+		kept = order[spec.order-1 : spec.order]
+	case spec.keep < spec.count:
+		sort.SliceStable(order, func(i, j int) bool {
+			if spec.keepHigh {
+				return order[i].Total > order[j].Total
+			}
+			return order[i].Total < order[j].Total
+		})
+		// Dice added by explosions join the pool; dropping dice drops the same number however many were added.
+		keep := spec.keep
+		if spec.drop {
+			keep = int64(len(order)) - (spec.count - spec.keep)
+		}
+		kept = order[:keep]
 	}
 
-	// Return a standard rolled value.
-	return rollIt("d", left, right)
+	// Mark every die dropped, then sum the kept dice.
+	ret := &DiceResult{Roll: *s, Dice: dice}
+	for _, die := range dice {
+		die.Dropped = true
+	}
+	for _, die := range kept {
+		die.Dropped = false
+		ret.Total, err = addInt64(ret.Total, die.Total)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
 }
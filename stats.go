@@ -0,0 +1,146 @@
+package diceprob
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+)
+
+// Mean - Expected value of the outcomes in the distribution.
+func (d *DiceProb) Mean() float64 {
+	ret, _ := d.mean().Float64()
+	return ret
+}
+
+// Variance - Variance of the outcomes in the distribution.
+func (d *DiceProb) Variance() float64 {
+	ret, _ := d.centralMoment(2).Float64()
+	return ret
+}
+
+// StdDev - Standard deviation of the outcomes in the distribution.
+func (d *DiceProb) StdDev() float64 {
+	return math.Sqrt(d.Variance())
+}
+
+// Skewness - Skewness of the outcomes in the distribution; zero when there is no variance.
+func (d *DiceProb) Skewness() float64 {
+	variance := d.Variance()
+	if variance == 0 {
+		return 0
+	}
+	moment, _ := d.centralMoment(3).Float64()
+	return moment / math.Pow(variance, 1.5)
+}
+
+// Kurtosis - Kurtosis (not excess kurtosis) of the outcomes in the distribution; zero when there is no variance.
+func (d *DiceProb) Kurtosis() float64 {
+	variance := d.Variance()
+	if variance == 0 {
+		return 0
+	}
+	moment, _ := d.centralMoment(4).Float64()
+	return moment / (variance * variance)
+}
+
+// Mode - Most frequent outcomes in the distribution, in ascending order.
+func (d *DiceProb) Mode() *[]int64 {
+	ret := []int64{}
+	highest := big.NewInt(0)
+	for _, outcome := range *d.outcomes {
+		switch (*d.distribution)[outcome].Cmp(highest) {
+		case 1:
+			highest = (*d.distribution)[outcome]
+			ret = []int64{outcome}
+		case 0:
+			ret = append(ret, outcome)
+		}
+	}
+	return &ret
+}
+
+// Median - Lowest outcome with at least half the probability at or below it, among the outcomes in the distribution.
+func (d *DiceProb) Median() int64 {
+	return d.Quantile(0.5)
+}
+
+// Quantile - Lowest outcome with at least probability p at or below it, among the outcomes in the distribution.
+func (d *DiceProb) Quantile(p float64) int64 {
+	return d.quantile(d.definedCDF(), p)
+}
+
+// Percentiles - Quantile for each of the percentages (0 to 100) given.
+func (d *DiceProb) Percentiles(percents ...float64) *[]int64 {
+	ret := []int64{}
+	cdf := d.definedCDF()
+	for _, percent := range percents {
+		ret = append(ret, d.quantile(cdf, percent/100))
+	}
+	return &ret
+}
+
+// Sample - Draw an outcome from the distribution by inverse transform sampling.
+func (d *DiceProb) Sample() int64 {
+	return d.Quantile(rand.New(d.options.source).Float64())
+}
+
+// definedCDF - Exact probability of rolling each outcome or lower, among the outcomes in the distribution; unlike
+// ExactCDF, the outcomes cut off or excluded from the distribution are left out, so the last outcome reaches 1.
+func (d *DiceProb) definedCDF() map[int64]*big.Rat {
+	ret := map[int64]*big.Rat{}
+	cumulative := big.NewInt(0)
+	for _, outcome := range *d.outcomes {
+		cumulative = big.NewInt(0).Add(cumulative, (*d.distribution)[outcome])
+		ret[outcome] = big.NewRat(0, 1).SetFrac(cumulative, d.defined)
+	}
+	return ret
+}
+
+// quantile - Lowest outcome with at least probability p at or below it in the cumulative distribution.
+func (d *DiceProb) quantile(cdf map[int64]*big.Rat, p float64) int64 {
+	if math.IsInf(p, 1) {
+		return d.Max()
+	}
+	target := big.NewRat(0, 1)
+	if !math.IsNaN(p) && !math.IsInf(p, -1) {
+		target.SetFloat64(p)
+	}
+	for _, outcome := range *d.outcomes {
+		if cdf[outcome].Cmp(target) >= 0 {
+			return outcome
+		}
+	}
+	return d.Max()
+}
+
+// mean - Exact expected value of the outcomes in the distribution.
+func (d *DiceProb) mean() *big.Rat {
+	if d.defined.Sign() == 0 {
+		return big.NewRat(0, 1)
+	}
+	sum := big.NewInt(0)
+	for outcome, frequency := range *d.distribution {
+		sum.Add(sum, big.NewInt(0).Mul(big.NewInt(outcome), frequency))
+	}
+	return big.NewRat(0, 1).SetFrac(sum, d.defined)
+}
+
+// centralMoment - Exact kth moment of the outcomes in the distribution about their mean.
+func (d *DiceProb) centralMoment(k int) *big.Rat {
+	if d.defined.Sign() == 0 {
+		return big.NewRat(0, 1)
+	}
+	mean := d.mean()
+	sum := big.NewRat(0, 1)
+	for outcome, frequency := range *d.distribution {
+		// Raise the outcome's deviation from the mean to the kth power, weighted by its frequency.
+		deviation := big.NewRat(outcome, 1)
+		deviation.Sub(deviation, mean)
+		term := big.NewRat(0, 1).SetInt(frequency)
+		for i := 0; i < k; i++ {
+			term.Mul(term, deviation)
+		}
+		sum.Add(sum, term)
+	}
+	return sum.Quo(sum, big.NewRat(0, 1).SetInt(d.defined))
+}
@@ -7,8 +7,17 @@ import (
 
 // String - Output the dice Expression as a string; top level of recursive output functions.
 func (e *Expression) String() string {
-	out := []string{e.Left.string()}
-	for _, r := range e.Right {
+	out := []string{e.Sum.string()}
+	if e.Comparison != nil {
+		out = append(out, e.Comparison.Operator.string(), e.Comparison.Sum.string())
+	}
+	return strings.Join(out, " ")
+}
+
+// String - Output the Sum as a string; part of the recursive output functions.
+func (s *Sum) string() string {
+	out := []string{s.Left.string()}
+	for _, r := range s.Right {
 		out = append(out, r.string())
 	}
 	return strings.Join(out, " ")
@@ -21,12 +30,30 @@ func (o Operator) string() string {
 		return "*"
 	case OpDiv:
 		return "/"
+	case OpDivFloor:
+		return "//"
+	case OpDivCeil:
+		return "/^"
+	case OpDivRound:
+		return "/~"
 	case OpSub:
 		return "-"
 	case OpAdd:
 		return "+"
+	case OpGt:
+		return ">"
+	case OpGe:
+		return ">="
+	case OpLt:
+		return "<"
+	case OpLe:
+		return "<="
+	case OpEq:
+		return "=="
+	case OpNe:
+		return "!="
 	}
-	panic("unsupported operator") // TODO - We can do better here.
+	return fmt.Sprintf("Operator(%d)", int(o))
 }
 
 // String - Output the Operator and Term as a string; part of the recursive output functions.
@@ -50,13 +77,37 @@ func (o *OpAtom) string() string {
 
 // String - Output the Atom as a string; part of the recursive output functions.
 func (a *Atom) string() string {
-	if a.Modifier != nil {
-		return fmt.Sprintf("%d", *a.Modifier)
+	ret := ""
+	switch {
+	case a.Modifier != nil:
+		ret = fmt.Sprintf("%d", *a.Modifier)
+	case a.RollExpr != nil:
+		ret = a.RollExpr.string()
+	case a.Unary != nil:
+		ret = a.Unary.string()
+	case a.Call != nil:
+		ret = a.Call.string()
+	default:
+		ret = "(" + a.SubExpression.String() + ")"
+	}
+	if a.Label != nil {
+		ret += " " + a.Label.string()
 	}
-	if a.RollExpr != nil {
-		return a.RollExpr.string()
+	return ret
+}
+
+// String - Output the Unary as a string; part of the recursive output functions.
+func (u *Unary) string() string {
+	return u.Operator.string() + u.Atom.string()
+}
+
+// String - Output the Call as a string; part of the recursive output functions.
+func (c *Call) string() string {
+	args := []string{}
+	for _, arg := range c.Args {
+		args = append(args, arg.String())
 	}
-	return "(" + a.SubExpression.String() + ")"
+	return c.Name + "(" + strings.Join(args, ", ") + ")"
 }
 
 // String - Output the DiceRoll as a string; the deepest of the recursive output functions.
@@ -64,3 +115,8 @@ func (s *DiceRoll) string() string {
 	ret := string(*s)
 	return ret
 }
+
+// String - Output the Label as a string, in brackets; the deepest of the recursive output functions.
+func (l *Label) string() string {
+	return "[" + string(*l) + "]"
+}
@@ -0,0 +1,68 @@
+package diceprob
+
+// validate - Check the Expression can be rolled and calculated; top-level of the recursive validation functions.
+func (e *Expression) validate() error {
+	if err := e.Sum.validate(); err != nil {
+		return err
+	}
+	if e.Comparison != nil {
+		return e.Comparison.Sum.validate()
+	}
+	return nil
+}
+
+// validate - Check the Sum can be rolled and calculated; part of the recursive validation functions.
+func (s *Sum) validate() error {
+	if err := s.Left.validate(); err != nil {
+		return err
+	}
+	for _, right := range s.Right {
+		if err := right.Term.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate - Check the Term can be rolled and calculated; part of the recursive validation functions.
+func (t *Term) validate() error {
+	if err := t.Left.validate(); err != nil {
+		return err
+	}
+	for _, right := range t.Right {
+		if err := right.Atom.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate - Check the Atom can be rolled and calculated; part of the recursive validation functions.
+func (a *Atom) validate() error {
+	switch {
+	case a.Modifier != nil:
+		return nil
+	case a.RollExpr != nil:
+		_, err := a.RollExpr.parse()
+		return err
+	case a.Unary != nil:
+		return a.Unary.Atom.validate()
+	case a.Call != nil:
+		return a.Call.validate()
+	default:
+		return a.SubExpression.validate()
+	}
+}
+
+// validate - Check the Call is of a known function, and its arguments can be rolled and calculated; part of the recursive validation functions.
+func (c *Call) validate() error {
+	if _, err := c.lookup(); err != nil {
+		return err
+	}
+	for _, arg := range c.Args {
+		if err := arg.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
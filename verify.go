@@ -0,0 +1,197 @@
+package diceprob
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// minExpected - Smallest expected count in a bin of the chi-square test; smaller bins are merged with their neighbours.
+const minExpected = 5
+
+// Verify - Roll the expression samples times, and test the rolls against the calculated distribution.
+// Returns the chi-square statistic and its p-value; a small p-value means the rolls do not follow the distribution.
+// Rolls cut off from the distribution by the explosion depth, or excluded by division by zero, are tested as a
+// single bin of their own, so the explosion depth should be deep enough to make the cut off probability small.
+func (d *DiceProb) Verify(samples int) (chiSquare, pValue float64, err error) {
+	if samples < 1 {
+		return 0, 0, fmt.Errorf("%w: %d", ErrInvalidSamples, samples)
+	}
+	if err := d.Calculate(); err != nil {
+		return 0, 0, err
+	}
+
+	// Roll the expression, counting the rolls outside the distribution together; a roll is cut off when one of its
+	// dice exploded more often than the distribution follows, whatever its total.
+	counts := map[int64]float64{}
+	other := float64(0)
+	r := rand.New(d.options.source)
+	for i := 0; i < samples; i++ {
+		result, err := d.parsed.roll(r)
+		switch {
+		case errors.Is(err, ErrDivideByZero):
+			other++
+			continue
+		case err != nil:
+			return 0, 0, err
+		}
+		if _, ok := (*d.distribution)[result.Total]; !ok || result.explosions() > d.options.explodeDepth {
+			other++
+			continue
+		}
+		counts[result.Total]++
+	}
+
+	// Merge neighbouring outcomes until each bin expects enough rolls.
+	type bin struct{ observed, expected float64 }
+	bins := []bin{}
+	current := bin{}
+	for _, outcome := range *d.outcomes {
+		current.observed += counts[outcome]
+		current.expected += (*d.probabilities)[outcome] * float64(samples)
+		if current.expected >= minExpected {
+			bins = append(bins, current)
+			current = bin{}
+		}
+	}
+	if current.expected > 0 || current.observed > 0 {
+		if len(bins) == 0 {
+			bins = append(bins, current)
+		} else {
+			bins[len(bins)-1].observed += current.observed
+			bins[len(bins)-1].expected += current.expected
+		}
+	}
+
+	// The rolls outside the distribution form their own bin, or join the last if too few are expected.
+	outside := bin{observed: other, expected: (d.TruncatedProbability() + d.UndefinedProbability()) * float64(samples)}
+	switch {
+	case outside.expected == 0 && outside.observed > 0:
+		return math.Inf(1), 0, nil
+	case outside.expected >= minExpected || len(bins) == 0:
+		bins = append(bins, outside)
+	default:
+		bins[len(bins)-1].observed += outside.observed
+		bins[len(bins)-1].expected += outside.expected
+	}
+
+	// With a single bin there is nothing to test.
+	if len(bins) < 2 {
+		return 0, 1, nil
+	}
+	for _, b := range bins {
+		chiSquare += (b.observed - b.expected) * (b.observed - b.expected) / b.expected
+	}
+	return chiSquare, gammaQ(float64(len(bins)-1)/2, chiSquare/2), nil
+}
+
+// explosions - Most explosions of any single die in the rolled Expression.
+func (e *ExpressionResult) explosions() int64 {
+	ret := e.SumResult.explosions()
+	if e.Comparison != nil {
+		ret = maxInt64(ret, e.Comparison.SumResult.explosions())
+	}
+	return ret
+}
+
+// explosions - Most explosions of any single die in the rolled Sum.
+func (s *SumResult) explosions() int64 {
+	ret := s.Left.explosions()
+	for _, r := range s.Right {
+		ret = maxInt64(ret, r.Term.explosions())
+	}
+	return ret
+}
+
+// explosions - Most explosions of any single die in the rolled Term.
+func (t *TermResult) explosions() int64 {
+	ret := t.Left.explosions()
+	for _, r := range t.Right {
+		ret = maxInt64(ret, r.Atom.explosions())
+	}
+	return ret
+}
+
+// explosions - Most explosions of any single die in the rolled Atom.
+func (a *AtomResult) explosions() int64 {
+	switch {
+	case a.Dice != nil:
+		return a.Dice.explosions()
+	case a.SubExpression != nil:
+		return a.SubExpression.explosions()
+	case a.Unary != nil:
+		return a.Unary.Atom.explosions()
+	case a.Call != nil:
+		ret := int64(0)
+		for _, arg := range a.Call.Args {
+			ret = maxInt64(ret, arg.explosions())
+		}
+		return ret
+	}
+	return 0
+}
+
+// explosions - Most explosions of any single die in the rolled dice. Every roll of a compounding die after the first was
+// an explosion; otherwise each explosion added the next die to the pool.
+func (s *DiceResult) explosions() int64 {
+	ret, chain := int64(0), int64(0)
+	for _, die := range s.Dice {
+		ret = maxInt64(ret, chain+int64(len(die.Rolls)-1))
+		if die.Exploded && len(die.Rolls) == 1 {
+			chain++
+		} else {
+			chain = 0
+		}
+	}
+	return ret
+}
+
+// gammaQ - Regularized upper incomplete gamma function Q(a, x); the chi-square p-value is Q(df/2, chiSquare/2).
+func gammaQ(a, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	lgamma, _ := math.Lgamma(a)
+	scale := math.Exp(-x + a*math.Log(x) - lgamma)
+
+	// Below a+1 the series for the lower function converges quickly; above it, the continued fraction for the upper.
+	if x < a+1 {
+		term := 1 / a
+		sum := term
+		for n := 1; n < 1000; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-15 {
+				break
+			}
+		}
+		return 1 - sum*scale
+	}
+
+	// Modified Lentz's method for the continued fraction.
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	f := 1 / b
+	h := f
+	for n := 1; n < 1000; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		f = an*f + b
+		if math.Abs(f) < tiny {
+			f = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		f = 1 / f
+		delta := f * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-15 {
+			break
+		}
+	}
+	return h * scale
+}
@@ -0,0 +1,76 @@
+package diceprob
+
+import (
+	"math/big"
+)
+
+// Opposed - Result of an opposed roll between two expressions.
+type Opposed struct {
+	a      *DiceProb // Expression rolled by the first side.
+	b      *DiceProb // Expression rolled by the second side.
+	margin *DiceProb // Margin of the first side's outcome over the second's.
+}
+
+// Versus - Analyse an opposed roll of a against b; a wins when its outcome is higher.
+func Versus(a, b *DiceProb) (*Opposed, error) {
+	// Calculate each side with its own options.
+	left, err := a.parsed.distribution(a.options)
+	if err != nil {
+		return nil, err
+	}
+	right, err := b.parsed.distribution(b.options)
+	if err != nil {
+		return nil, err
+	}
+
+	// The margin is the difference of the two sides, a-b.
+	calculated, err := OpSub.distribution(left, right, a.options)
+	if err != nil {
+		return nil, err
+	}
+	margin := &DiceProb{
+		expression: "(" + a.expression + ") - (" + b.expression + ")",
+		parsed: &Expression{Sum: Sum{
+			Left:  &Term{Left: &Atom{SubExpression: a.parsed}},
+			Right: []*OpTerm{{Operator: OpSub, Term: &Term{Left: &Atom{SubExpression: b.parsed}}}},
+		}},
+		probabilities: &map[int64]float64{},
+		exact:         &map[int64]*big.Rat{},
+		options:       a.options,
+	}
+	if err := margin.calculate(calculated); err != nil {
+		return nil, err
+	}
+
+	return &Opposed{a: a, b: b, margin: margin}, nil
+}
+
+// A - Expression rolled by the first side.
+func (o *Opposed) A() *DiceProb {
+	return o.a
+}
+
+// B - Expression rolled by the second side.
+func (o *Opposed) B() *DiceProb {
+	return o.b
+}
+
+// Margin - Distribution of the margin a-b; positive when the first side wins.
+func (o *Opposed) Margin() *DiceProb {
+	return o.margin
+}
+
+// Win - Probability the first side rolls higher than the second.
+func (o *Opposed) Win() float64 {
+	return o.margin.AtLeast(1)
+}
+
+// Tie - Probability both sides roll the same.
+func (o *Opposed) Tie() float64 {
+	return o.margin.Between(0, 0)
+}
+
+// Lose - Probability the first side rolls lower than the second.
+func (o *Opposed) Lose() float64 {
+	return o.margin.AtMost(-1)
+}